@@ -0,0 +1,361 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("auth",
+		"this tests for JWT and authentication libraries used without verifying algorithms, signatures or expiry",
+		authCheck,
+		fileNode)
+}
+
+// what an auth rule checks
+const (
+	authCall	= iota	// any call is reported
+	authIdent		// any use of the identifier is reported
+	authKeyFunc		// the key function argument must check the algorithm and not return a constant key
+	authKey			// the key argument must not be a constant
+	authFalse		// the call is reported when its argument is false
+	authNone		// the call is reported when an argument is the string "none"
+	authField		// a composite literal field set to true is reported
+	authRequires		// the call is reported unless option is used in the same function or the parser's definition
+)
+
+// authRule describes one misuse of an auth library.
+// name is a function, method, option or field of the library
+type authRule struct {
+	libs	[]string
+	name	string
+	kind	int
+	arg	int
+	option	string
+	message	string
+}
+
+// import path prefixes of the libraries the rules cover
+var (
+	jwtLibs		= []string{"github.com/golang-jwt/jwt", "github.com/dgrijalva/jwt-go", "github.com/form3tech-oss/jwt-go"}
+	jwtV5Libs	= []string{"github.com/golang-jwt/jwt/v5"}
+	joseLibs	= []string{"github.com/go-jose/go-jose", "gopkg.in/square/go-jose", "gopkg.in/go-jose/go-jose"}
+	jwxLibs		= []string{"github.com/lestrrat-go/jwx"}
+)
+
+// authRules lists the checks for each library, add rules here for other libraries
+func authRules() []authRule {
+	return []authRule{
+		{libs: jwtLibs, name: "Parse", kind: authKeyFunc, arg: 1},
+		{libs: jwtLibs, name: "ParseWithClaims", kind: authKeyFunc, arg: 2},
+		{libs: jwtLibs, name: "ParseUnverified", kind: authCall,
+			message: "ParseUnverified does not check the signature, claims from it cannot be trusted"},
+		{libs: jwtLibs, name: "SigningMethodNone", kind: authIdent,
+			message: "audit use of the none algorithm, unsigned tokens must never be accepted"},
+		{libs: jwtLibs, name: "UnsafeAllowNoneSignatureType", kind: authIdent,
+			message: "UnsafeAllowNoneSignatureType lets unsigned tokens through"},
+		{libs: jwtLibs, name: "WithValidMethods", kind: authNone,
+			message: "the none algorithm is an allowed signing method"},
+		{libs: jwtLibs, name: "SignedString", kind: authKey, arg: 0,
+			message: "token signed with a constant HMAC key"},
+		{libs: jwtLibs, name: "SkipClaimsValidation", kind: authField,
+			message: "SkipClaimsValidation turns off expiry checks"},
+		{libs: jwtLibs, name: "WithoutClaimsValidation", kind: authCall,
+			message: "WithoutClaimsValidation turns off expiry checks"},
+		{libs: jwtV5Libs, name: "Parse", kind: authRequires, option: "WithExpirationRequired",
+			message: "tokens without an exp claim are accepted, add jwt.WithExpirationRequired()"},
+		{libs: jwtV5Libs, name: "ParseWithClaims", kind: authRequires, option: "WithExpirationRequired",
+			message: "tokens without an exp claim are accepted, add jwt.WithExpirationRequired()"},
+		{libs: joseLibs, name: "UnsafeClaimsWithoutVerification", kind: authCall,
+			message: "UnsafeClaimsWithoutVerification does not check the signature, claims from it cannot be trusted"},
+		{libs: joseLibs, name: "UnsafePayloadWithoutVerification", kind: authCall,
+			message: "UnsafePayloadWithoutVerification does not check the signature"},
+		{libs: jwxLibs, name: "ParseInsecure", kind: authCall,
+			message: "ParseInsecure does not check the signature, claims from it cannot be trusted"},
+		{libs: jwxLibs, name: "WithVerify", kind: authFalse,
+			message: "signature verification turned off"},
+		{libs: jwxLibs, name: "WithValidate", kind: authFalse,
+			message: "claim validation, including expiry, turned off"},
+	}
+}
+
+// matchesLib reports whether an import path belongs to one of libs
+func matchesLib(path string, libs []string) bool {
+	for _, lib := range libs {
+		if path == lib || strings.HasPrefix(path, lib + "/") || strings.HasPrefix(path, lib + ".") {
+			return true;
+		}
+	}
+	return false;
+}
+
+// fromLib reports whether a selector like jwt.Parse or parser.Parse belongs to one of libs.
+// a method whose receiver type is unknown, because the import failed,
+// is assumed to belong to a library the file imports
+func fromLib(f *File, sel *ast.SelectorExpr, libs []string) bool {
+	if path := importedPath(f, sel); path != "" {
+		return matchesLib(path, libs);
+	}
+	if t := f.pkg.info.TypeOf(sel.X); t != nil && t != types.Typ[types.Invalid] {
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem();
+		}
+		named, ok := t.(*types.Named);
+		return ok && named.Obj().Pkg() != nil && matchesLib(named.Obj().Pkg().Path(), libs);
+	}
+	for _, imp := range f.file.Imports {
+		if matchesLib(strings.Trim(imp.Path.Value, "\"`"), libs) {
+			return true;
+		}
+	}
+	return false;
+}
+
+func authCheck(f *File, node ast.Node) {
+	file, ok := node.(*ast.File);
+	if !ok {
+		return;
+	}
+	imported := false
+	for _, imp := range file.Imports {
+		for _, rule := range authRules() {
+			if matchesLib(strings.Trim(imp.Path.Value, "\"`"), rule.libs) {
+				imported = true;
+			}
+		}
+	}
+	if !imported {
+		return;
+	}
+	for _, decl := range file.Decls {
+		// options are looked for in the enclosing function
+		authScope(f, decl);
+	}
+}
+
+// authScope applies the rules to one top level declaration
+func authScope(f *File, scope ast.Node) {
+	rules := authRules();
+	ast.Inspect(scope, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CallExpr:
+			sel, ok := x.Fun.(*ast.SelectorExpr);
+			if !ok {
+				break;
+			}
+			for _, rule := range rules {
+				if rule.name == sel.Sel.Name && rule.kind != authIdent && fromLib(f, sel, rule.libs) {
+					authCallRule(f, scope, x, rule);
+				}
+			}
+		case *ast.SelectorExpr:
+			for _, rule := range rules {
+				if rule.kind == authIdent && rule.name == x.Sel.Name && fromLib(f, x, rule.libs) {
+					f.Reportf(x.Pos(), "%s: %s", rule.message, f.ASTString(x));
+				}
+			}
+		case *ast.CompositeLit:
+			// jwt.Parser{SkipClaimsValidation: true}
+			sel, ok := x.Type.(*ast.SelectorExpr);
+			if !ok {
+				break;
+			}
+			for _, elt := range x.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr);
+				if !ok || !isTrue(f, kv.Value) {
+					continue
+				}
+				key, ok := kv.Key.(*ast.Ident);
+				if !ok {
+					continue
+				}
+				for _, rule := range rules {
+					if rule.kind == authField && rule.name == key.Name && fromLib(f, sel, rule.libs) {
+						f.Reportf(kv.Pos(), "%s: %s", rule.message, f.ASTString(kv));
+					}
+				}
+			}
+		}
+		return true;
+	})
+}
+
+// authCallRule checks a call to a library function against one rule
+func authCallRule(f *File, scope ast.Node, call *ast.CallExpr, rule authRule) {
+	switch rule.kind {
+	case authCall:
+		f.Reportf(call.Pos(), "%s: %s", rule.message, f.ASTString(call.Fun));
+	case authFalse:
+		if len(call.Args) == 1 && isFalse(f, call.Args[0]) {
+			f.Reportf(call.Pos(), "%s: %s", rule.message, f.ASTString(call));
+		}
+	case authNone:
+		ast.Inspect(call, func(n ast.Node) bool {
+			if x, ok := n.(ast.Expr); ok {
+				if s, ok := stringValue(f, x); ok && strings.EqualFold(s, "none") {
+					f.Reportf(call.Pos(), "%s: %s", rule.message, f.ASTString(call));
+					return false;
+				}
+			}
+			return true;
+		})
+	case authKey:
+		if rule.arg < len(call.Args) && isConstantKey(f, call.Args[rule.arg]) {
+			f.Reportf(call.Pos(), "%s: %s", rule.message, f.ASTString(call));
+		}
+	case authRequires:
+		if !usesOption(f, scope, call, rule.option) {
+			f.Reportf(call.Pos(), "%s: %s", rule.message, f.ASTString(call.Fun));
+		}
+	case authKeyFunc:
+		if rule.arg < len(call.Args) {
+			keyFuncCheck(f, scope, call, call.Args[rule.arg]);
+		}
+	}
+}
+
+// keyFuncCheck reports a key function that hands out the key
+// without looking at the token's algorithm, or that returns a constant key
+func keyFuncCheck(f *File, scope ast.Node, call *ast.CallExpr, keyFunc ast.Expr) {
+	var body *ast.BlockStmt
+	switch k := keyFunc.(type) {
+	case *ast.FuncLit:
+		body = k.Body;
+	case *ast.Ident:
+		if decl, ok := packageFuncs(f)[f.pkg.info.Uses[k]]; ok {
+			body = decl.Body;
+		}
+	}
+	if body == nil {
+		return;
+	}
+	// jwt.WithValidMethods restricts the algorithm for the key function
+	checksAlg := usesOption(f, scope, call, "WithValidMethods")
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			// token.Method.(*jwt.SigningMethodHMAC) or token.Method.Alg()
+			if x.Sel.Name == "Method" {
+				checksAlg = true;
+			}
+		case *ast.IndexExpr:
+			// token.Header["alg"]
+			if s, ok := stringValue(f, x.Index); ok && s == "alg" {
+				checksAlg = true;
+			}
+		case *ast.ReturnStmt:
+			if len(x.Results) > 0 && isConstantKey(f, x.Results[0]) {
+				f.Reportf(x.Pos(), "key function returns a constant HMAC key: %s", f.ASTString(x.Results[0]));
+			}
+		}
+		return true;
+	})
+	if !checksAlg {
+		f.Reportf(call.Pos(), "key function does not check token.Method, tokens signed with another algorithm such as none or HS256 with a public key are accepted: %s", f.ASTString(call.Fun));
+	}
+}
+
+// isConstantKey reports whether a key is a literal, a constant
+// or a variable defined from one, i.e. []byte("secret")
+func isConstantKey(f *File, x ast.Expr) bool {
+	if _, ok := stringValue(f, x); ok {
+		return true;
+	}
+	// []byte(key) where key was defined with a literal
+	if call, ok := x.(*ast.CallExpr); ok && len(call.Args) == 1 && f.pkg.info.Types[call.Fun].IsType() {
+		x = call.Args[0];
+	}
+	if id, ok := x.(*ast.Ident); ok {
+		if obj, ok := f.pkg.info.Uses[id].(*types.Var); ok {
+			if def := definitionOf(f, obj); def != nil && !isAssigned(f, obj) {
+				_, ok := stringValue(f, def);
+				return ok;
+			}
+		}
+	}
+	return false;
+}
+
+// isAssigned reports whether a variable is assigned again after its definition
+// anywhere in the package, or has its address passed to a call like flag.StringVar,
+// i.e. a key loaded from configuration at startup
+func isAssigned(f *File, obj types.Object) bool {
+	assigned := false
+	is := func(x ast.Expr) bool {
+		id, ok := ast.Unparen(x).(*ast.Ident);
+		return ok && f.pkg.info.Uses[id] == obj;
+	}
+	for _, file := range f.pkg.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.AssignStmt:
+				for _, lhs := range x.Lhs {
+					if is(lhs) {
+						assigned = true;
+					}
+				}
+			case *ast.CallExpr:
+				for _, arg := range x.Args {
+					if u, ok := arg.(*ast.UnaryExpr); ok && u.Op == token.AND && is(u.X) {
+						assigned = true;
+					}
+				}
+			}
+			return !assigned;
+		})
+		if assigned {
+			break;
+		}
+	}
+	return assigned;
+}
+
+// usesOption reports whether the option name is called in scope
+// or in the definition of the parser the call is made on, which may be a package level variable
+func usesOption(f *File, scope ast.Node, call *ast.CallExpr, name string) bool {
+	if callsNamed(scope, name) {
+		return true;
+	}
+	// parser.Parse(raw, keyFunc)
+	sel, ok := call.Fun.(*ast.SelectorExpr);
+	if !ok {
+		return false;
+	}
+	id, ok := sel.X.(*ast.Ident);
+	if !ok {
+		return false;
+	}
+	if obj, ok := f.pkg.info.Uses[id].(*types.Var); ok {
+		if def := definitionOf(f, obj); def != nil {
+			return callsNamed(def, name);
+		}
+	}
+	return false;
+}
+
+// callsNamed reports whether node contains a call to a function or method called name
+func callsNamed(node ast.Node, name string) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && getFuncName(call) == name {
+			found = true;
+		}
+		return !found;
+	})
+	return found;
+}
+
+// isFalse is isTrue for false
+func isFalse(f *File, x ast.Expr) bool {
+	if v := f.pkg.info.Types[x].Value; v != nil && v.Kind() == constant.Bool {
+		return !constant.BoolVal(v);
+	}
+	id, ok := x.(*ast.Ident);
+	return ok && id.Name == "false";
+}
@@ -0,0 +1,289 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// a control flow graph over the statements of one function body.
+// it is built from the AST alone and is enough for simple path questions
+// like whether an error was checked before a value was used.
+// function literals are not entered, they get a graph of their own.
+type cfg struct {
+	blocks	[]*cfgBlock
+	entry	*cfgBlock
+}
+
+// cfgBlock is a run of statements and expressions executed in order
+type cfgBlock struct {
+	index	int
+	nodes	[]ast.Node
+	succs	[]cfgEdge
+}
+
+// cfgEdge is taken when cond evaluates to branch,
+// or always when cond is nil
+type cfgEdge struct {
+	to	*cfgBlock
+	cond	ast.Expr
+	branch	bool
+}
+
+// the blocks break and continue jump to inside a loop, switch or select
+type cfgTargets struct {
+	label	string
+	brk	*cfgBlock
+	cont	*cfgBlock
+}
+
+type cfgBuilder struct {
+	f	*File
+	g	*cfg
+	cur	*cfgBlock
+	targets	[]cfgTargets
+}
+
+// noReturnCalls are calls that never return to the caller
+func noReturnCalls() map[string]bool {
+	calls := make(map[string]bool)
+	calls["panic"]				= true
+	calls["os.Exit"]			= true
+	calls["runtime.Goexit"]			= true
+	calls["log.Fatal"]			= true
+	calls["log.Fatalf"]			= true
+	calls["log.Fatalln"]			= true
+	calls["log.Panic"]			= true
+	calls["log.Panicf"]			= true
+	calls["log.Panicln"]			= true
+	calls["(*log.Logger).Fatal"]		= true
+	calls["(*log.Logger).Fatalf"]		= true
+	calls["(*log.Logger).Fatalln"]		= true
+	calls["(*log.Logger).Panic"]		= true
+	calls["(*log.Logger).Panicf"]		= true
+	calls["(*log.Logger).Panicln"]		= true
+	calls["(*testing.common).Fatal"]	= true
+	calls["(*testing.common).Fatalf"]	= true
+	calls["(*testing.common).FailNow"]	= true
+
+	return calls;
+}
+
+// newCFG builds the control flow graph of a function body
+func newCFG(f *File, body *ast.BlockStmt) *cfg {
+	b := &cfgBuilder{f: f, g: &cfg{}}
+	b.g.entry = b.newBlock();
+	b.cur = b.g.entry;
+	if body != nil {
+		b.stmtList(body.List);
+	}
+	return b.g;
+}
+
+func (b *cfgBuilder) newBlock() *cfgBlock {
+	block := &cfgBlock{index: len(b.g.blocks)}
+	b.g.blocks = append(b.g.blocks, block);
+	return block;
+}
+
+func (b *cfgBuilder) add(n ast.Node) {
+	b.cur.nodes = append(b.cur.nodes, n);
+}
+
+// jump ends the current block with an unconditional edge
+func (b *cfgBuilder) jump(to *cfgBlock) {
+	b.cur.succs = append(b.cur.succs, cfgEdge{to: to});
+}
+
+// branch ends the current block with an edge for each value of cond
+func (b *cfgBuilder) branch(cond ast.Expr, then, els *cfgBlock) {
+	b.cur.succs = append(b.cur.succs, cfgEdge{then, cond, true}, cfgEdge{els, cond, false});
+}
+
+// dead starts a block nothing jumps to, for statements after a return
+func (b *cfgBuilder) dead() {
+	b.cur = b.newBlock();
+}
+
+func (b *cfgBuilder) stmtList(list []ast.Stmt) {
+	for _, s := range list {
+		b.stmt(s, "");
+	}
+}
+
+// target finds the innermost break or continue target, or the labelled one
+func (b *cfgBuilder) target(label *ast.Ident, cont bool) *cfgBlock {
+	for i := len(b.targets) - 1; i >= 0; i-- {
+		t := b.targets[i];
+		if label != nil && t.label != label.Name {
+			continue
+		}
+		if cont {
+			if t.cont != nil {
+				return t.cont;
+			}
+			continue
+		}
+		return t.brk;
+	}
+	return nil;
+}
+
+func (b *cfgBuilder) stmt(s ast.Stmt, label string) {
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		b.stmtList(s.List);
+	case *ast.LabeledStmt:
+		b.stmt(s.Stmt, s.Label.Name);
+	case *ast.IfStmt:
+		if s.Init != nil {
+			b.stmt(s.Init, "");
+		}
+		b.add(s.Cond);
+		then, after := b.newBlock(), b.newBlock()
+		els := after
+		if s.Else != nil {
+			els = b.newBlock();
+		}
+		b.branch(s.Cond, then, els);
+		b.cur = then;
+		b.stmt(s.Body, "");
+		b.jump(after);
+		if s.Else != nil {
+			b.cur = els;
+			b.stmt(s.Else, "");
+			b.jump(after);
+		}
+		b.cur = after;
+	case *ast.ForStmt:
+		if s.Init != nil {
+			b.stmt(s.Init, "");
+		}
+		head, body, post, after := b.newBlock(), b.newBlock(), b.newBlock(), b.newBlock()
+		b.jump(head);
+		b.cur = head;
+		if s.Cond != nil {
+			b.add(s.Cond);
+			b.branch(s.Cond, body, after);
+		} else {
+			b.jump(body);
+		}
+		b.targets = append(b.targets, cfgTargets{label, after, post});
+		b.cur = body;
+		b.stmt(s.Body, "");
+		b.jump(post);
+		b.cur = post;
+		if s.Post != nil {
+			b.stmt(s.Post, "");
+		}
+		b.jump(head);
+		b.targets = b.targets[:len(b.targets)-1];
+		b.cur = after;
+	case *ast.RangeStmt:
+		b.add(s.X);
+		head, body, after := b.newBlock(), b.newBlock(), b.newBlock()
+		b.jump(head);
+		b.cur = head;
+		// the range statement itself stands for assigning the key and value
+		b.add(s);
+		b.jump(body);
+		b.jump(after);
+		b.targets = append(b.targets, cfgTargets{label, after, head});
+		b.cur = body;
+		b.stmt(s.Body, "");
+		b.jump(head);
+		b.targets = b.targets[:len(b.targets)-1];
+		b.cur = after;
+	case *ast.SwitchStmt:
+		if s.Init != nil {
+			b.stmt(s.Init, "");
+		}
+		if s.Tag != nil {
+			b.add(s.Tag);
+		}
+		b.clauses(s.Body, label, false);
+	case *ast.TypeSwitchStmt:
+		if s.Init != nil {
+			b.stmt(s.Init, "");
+		}
+		b.add(s.Assign);
+		b.clauses(s.Body, label, false);
+	case *ast.SelectStmt:
+		// a select without default waits for one of its cases
+		b.clauses(s.Body, label, true);
+	case *ast.BranchStmt:
+		switch s.Tok {
+		case token.BREAK:
+			if to := b.target(s.Label, false); to != nil {
+				b.jump(to);
+			}
+			b.dead();
+		case token.CONTINUE:
+			if to := b.target(s.Label, true); to != nil {
+				b.jump(to);
+			}
+			b.dead();
+		case token.GOTO:
+			// not followed, the path ends here
+			b.dead();
+		}
+		// fallthrough is treated as leaving the switch
+	case *ast.ReturnStmt:
+		b.add(s);
+		b.dead();
+	case *ast.ExprStmt:
+		b.add(s);
+		if call, ok := s.X.(*ast.CallExpr); ok && b.noReturn(call) {
+			b.dead();
+		}
+	default:
+		b.add(s);
+	}
+}
+
+// clauses adds the cases of a switch or select, each entered from the current block.
+// unless exhaustive, the statement is skipped when no case matches and there is no default
+func (b *cfgBuilder) clauses(body *ast.BlockStmt, label string, exhaustive bool) {
+	head, after := b.cur, b.newBlock()
+	b.targets = append(b.targets, cfgTargets{label: label, brk: after});
+	hasDefault := exhaustive
+	for _, clause := range body.List {
+		b.cur = b.newBlock();
+		head.succs = append(head.succs, cfgEdge{to: b.cur});
+		switch c := clause.(type) {
+		case *ast.CaseClause:
+			if c.List == nil {
+				hasDefault = true;
+			}
+			for _, x := range c.List {
+				b.add(x);
+			}
+			b.stmtList(c.Body);
+		case *ast.CommClause:
+			if c.Comm == nil {
+				hasDefault = true;
+			} else {
+				b.add(c.Comm);
+			}
+			b.stmtList(c.Body);
+		}
+		b.jump(after);
+	}
+	if !hasDefault {
+		head.succs = append(head.succs, cfgEdge{to: after});
+	}
+	b.targets = b.targets[:len(b.targets)-1];
+	b.cur = after;
+}
+
+// noReturn reports whether a call ends the path, like panic or log.Fatal
+func (b *cfgBuilder) noReturn(call *ast.CallExpr) bool {
+	name := calleeName(b.f, call);
+	// panic is a builtin and has no types.Func
+	if id, ok := call.Fun.(*ast.Ident); ok && name == "" {
+		name = id.Name;
+	}
+	return noReturnCalls()[name];
+}
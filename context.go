@@ -0,0 +1,208 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("context",
+		"this tests for lost cancel functions, detached contexts and contexts stored in structs",
+		contextCheck,
+		funcDecl,
+		funcLit,
+		structType)
+}
+
+// isContext reports whether t is context.Context
+func isContext(t types.Type) bool {
+	return isNamedType(t, "context", "Context");
+}
+
+// isCancelFunc reports whether t is the cancel function of a derived context
+func isCancelFunc(t types.Type) bool {
+	return isNamedType(t, "context", "CancelFunc") || isNamedType(t, "context", "CancelCauseFunc");
+}
+
+func contextCheck(f *File, node ast.Node) {
+	if st, ok := node.(*ast.StructType); ok {
+		for _, field := range st.Fields.List {
+			if isContext(f.pkg.info.TypeOf(field.Type)) {
+				f.Reportf(field.Pos(), "context stored in a struct field, pass it as the first parameter instead");
+			}
+		}
+		return;
+	}
+	var typ *ast.FuncType
+	var body *ast.BlockStmt
+	switch fun := node.(type) {
+	case *ast.FuncDecl:
+		typ, body = fun.Type, fun.Body;
+	case *ast.FuncLit:
+		typ, body = fun.Type, fun.Body;
+	}
+	if body == nil {
+		return;
+	}
+	cancelCheck(f, body);
+	if hasContextParam(f, typ) {
+		detachedContextCheck(f, body);
+	}
+	if handler := handlerBody(f, node); handler != nil {
+		handlerContextCheck(f, handler);
+	}
+}
+
+// hasContextParam reports whether a function receives a context.Context
+func hasContextParam(f *File, typ *ast.FuncType) bool {
+	for _, field := range typ.Params.List {
+		if isContext(f.pkg.info.TypeOf(field.Type)) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// cancelCheck reports cancel functions that are discarded
+// or not called on every path out of the function
+func cancelCheck(f *File, body *ast.BlockStmt) {
+	followStmts(body, func(stmt ast.Stmt, rest [][]ast.Stmt) {
+		assign, ok := stmt.(*ast.AssignStmt);
+		if !ok || len(assign.Rhs) != 1 {
+			return;
+		}
+		call, ok := assign.Rhs[0].(*ast.CallExpr);
+		if !ok {
+			return;
+		}
+		index := resultIndex(f, call, isCancelFunc)
+		if index < 0 || index >= len(assign.Lhs) {
+			return;
+		}
+		id, ok := assign.Lhs[index].(*ast.Ident);
+		if !ok {
+			// stored in a field or map, released elsewhere
+			return;
+		}
+		if id.Name == "_" {
+			f.Reportf(assign.Pos(), "cancel function discarded, the context leaks until its parent is done: %s", f.ASTString(call));
+			return;
+		}
+		if cancelEscapes(f, body, f.pkg.info.ObjectOf(id)) {
+			return;
+		}
+		released, leak := releases(f, rest, id.Name);
+		if leak != nil {
+			f.Reportf(leak.Pos(), "returns without calling %s from line %d", id.Name, f.fset.Position(assign.Pos()).Line);
+		} else if !released {
+			f.Reportf(assign.Pos(), "%s is not called on every path: %s", id.Name, f.ASTString(call));
+		}
+	})
+}
+
+// cancelEscapes reports whether a cancel function is used other than by calling it,
+// i.e. returned, passed on, stored or used in a closure, which leaves calling it to someone else
+func cancelEscapes(f *File, body *ast.BlockStmt, obj types.Object) bool {
+	if obj == nil {
+		return true;
+	}
+	escapes := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			// go func() { <-done; cancel() }() leaves calling it to the closure
+			ast.Inspect(x.Body, func(m ast.Node) bool {
+				if id, ok := m.(*ast.Ident); ok && f.pkg.info.Uses[id] == obj {
+					escapes = true;
+				}
+				return !escapes;
+			})
+			return false;
+		case *ast.CallExpr:
+			// cancel() itself
+			if id, ok := x.Fun.(*ast.Ident); ok && f.pkg.info.Uses[id] == obj {
+				for _, arg := range x.Args {
+					ast.Inspect(arg, func(m ast.Node) bool {
+						if id, ok := m.(*ast.Ident); ok && f.pkg.info.Uses[id] == obj {
+							escapes = true;
+						}
+						return !escapes;
+					})
+				}
+				return false;
+			}
+		case *ast.Ident:
+			if f.pkg.info.Uses[x] == obj {
+				escapes = true;
+			}
+		}
+		return !escapes;
+	})
+	return escapes;
+}
+
+// detachedContextCheck reports new root contexts made in a function
+// that was given a context to use
+func detachedContextCheck(f *File, body *ast.BlockStmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			// closures with their own context are checked on their own
+			return !hasContextParam(f, x.Type);
+		case *ast.CallExpr:
+			switch calleeName(f, x) {
+			case "context.Background", "context.TODO":
+				f.Reportf(x.Pos(), "%s ignores the context passed to this function and its cancellation", f.ASTString(x));
+			}
+		}
+		return true;
+	})
+}
+
+// handlerContextCheck reports outgoing requests and dials in an HTTP handler
+// that do not take the request's context, so they outlive a client that went away
+func handlerContextCheck(f *File, body *ast.BlockStmt) {
+	calls := outgoingCalls();
+	// req.WithContext(r.Context()) attaches it later
+	attached := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && getFuncName(call) == "WithContext" {
+			attached = true;
+		}
+		return !attached;
+	})
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		name := calleeName(f, call);
+		if _, ok := calls[name]; !ok || strings.Contains(name, "Context") {
+			return true;
+		}
+		switch name {
+		case "(*net/http.Client).Do", "net/http/httputil.NewSingleHostReverseProxy":
+			// Do sends a request built elsewhere, the proxy uses the incoming context
+			return true;
+		case "net/http.NewRequest":
+			if attached {
+				return true;
+			}
+		}
+		f.Reportf(call.Pos(), "outgoing call in HTTP handler without r.Context(), use the %s form that takes a context: %s", contextForm(name), f.ASTString(call));
+		return true;
+	})
+}
+
+// contextForm names the context taking alternative to an outgoing call
+func contextForm(name string) string {
+	switch {
+	case strings.HasPrefix(name, "net."), strings.HasPrefix(name, "(*net.Dialer)"):
+		return "DialContext";
+	}
+	return "NewRequestWithContext";
+}
@@ -0,0 +1,229 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("cookie",
+		"this tests for cookies set without Secure, HttpOnly or SameSite",
+		cookieCheck,
+		funcDecl,
+		funcLit)
+}
+
+// name fragments of cookies that hold a session or credentials
+var sessionCookieNames = []string{"session", "sess", "sid", "auth", "token", "jwt", "login", "remember", "csrf"}
+
+// cookieFields maps cookie attributes to the expression they were set to
+type cookieFields map[string]ast.Expr
+
+// isCookie reports whether t is http.Cookie or *http.Cookie
+func isCookie(t types.Type) bool {
+	return isNamedType(t, "net/http", "Cookie");
+}
+
+// cookieCheck tracks http.Cookie values built up within a function
+// and reports them where they are sent with http.SetCookie,
+// attributes only matter on cookies sent in a response
+func cookieCheck(f *File, node ast.Node) {
+	var body *ast.BlockStmt
+	switch fun := node.(type) {
+	case *ast.FuncDecl:
+		body = fun.Body;
+	case *ast.FuncLit:
+		// http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {...})
+		body = handlerBody(f, fun);
+	}
+	if body == nil {
+		return;
+	}
+	cookies := make(map[types.Object]cookieFields)
+	get := func(obj types.Object) cookieFields {
+		if cookies[obj] == nil {
+			cookies[obj] = make(cookieFields);
+		}
+		return cookies[obj];
+	}
+	// cookies made here, others come from callers or helpers
+	// and may already have their attributes set
+	built := make(map[types.Object]bool)
+
+	// the first pass collects literals and field assignments per variable
+	inspectOwn(f, body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			for i, lhs := range x.Lhs {
+				if i >= len(x.Rhs) {
+					break;
+				}
+				if sel, ok := lhs.(*ast.SelectorExpr); ok && isCookie(f.pkg.info.TypeOf(sel.X)) {
+					// c.Secure = true
+					if id := rootIdent(sel.X); id != nil {
+						get(f.pkg.info.ObjectOf(id))[sel.Sel.Name] = x.Rhs[i];
+					}
+				} else if id, ok := lhs.(*ast.Ident); ok {
+					if lit := cookieLiteral(f, x.Rhs[i]); lit != nil {
+						addLiteralFields(get(f.pkg.info.ObjectOf(id)), lit);
+						built[f.pkg.info.ObjectOf(id)] = true;
+					} else if isNewCookie(f, x.Rhs[i]) {
+						built[f.pkg.info.ObjectOf(id)] = true;
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range x.Names {
+				obj := f.pkg.info.ObjectOf(name)
+				if i < len(x.Values) {
+					if lit := cookieLiteral(f, x.Values[i]); lit != nil {
+						addLiteralFields(get(obj), lit);
+						built[obj] = true;
+					} else if isNewCookie(f, x.Values[i]) {
+						built[obj] = true;
+					}
+				} else if len(x.Values) == 0 && obj != nil {
+					// var c http.Cookie, a nil *http.Cookie is not a cookie
+					_, ptr := obj.Type().(*types.Pointer)
+					built[obj] = !ptr && isCookie(obj.Type());
+				}
+			}
+		}
+		return true;
+	})
+
+	inspectOwn(f, body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		var arg ast.Expr
+		switch calleeName(f, call) {
+		case "net/http.SetCookie":
+			if len(call.Args) == 2 {
+				arg = call.Args[1];
+			}
+		case "(net/http.Header).Set", "(net/http.Header).Add":
+			// w.Header().Add("Set-Cookie", c.String())
+			if len(call.Args) == 2 {
+				if v := f.pkg.info.Types[call.Args[0]].Value; v != nil && v.Kind() == constant.String &&
+					strings.EqualFold(constant.StringVal(v), "Set-Cookie") {
+					if c, ok := call.Args[1].(*ast.CallExpr); ok && getFuncName(c) == "String" {
+						if sel, ok := c.Fun.(*ast.SelectorExpr); ok && isCookie(f.pkg.info.TypeOf(sel.X)) {
+							arg = sel.X;
+						}
+					}
+				}
+			}
+		}
+		if arg == nil {
+			return true;
+		}
+		fields := make(cookieFields)
+		if lit := cookieLiteral(f, arg); lit != nil {
+			addLiteralFields(fields, lit);
+		} else if id := rootIdent(arg); id != nil && built[f.pkg.info.ObjectOf(id)] {
+			fields = cookies[f.pkg.info.ObjectOf(id)];
+		} else {
+			return true;
+		}
+		reportCookie(f, call, arg, fields);
+		return true;
+	})
+}
+
+// cookieLiteral returns x as an http.Cookie literal, looking through &
+func cookieLiteral(f *File, x ast.Expr) *ast.CompositeLit {
+	if u, ok := x.(*ast.UnaryExpr); ok {
+		x = u.X;
+	}
+	if lit, ok := x.(*ast.CompositeLit); ok && isCookie(f.pkg.info.TypeOf(lit)) {
+		return lit;
+	}
+	return nil;
+}
+
+// isNewCookie reports whether x is new(http.Cookie)
+func isNewCookie(f *File, x ast.Expr) bool {
+	call, ok := x.(*ast.CallExpr);
+	if !ok || len(call.Args) != 1 {
+		return false;
+	}
+	id, ok := call.Fun.(*ast.Ident);
+	if !ok || id.Name != "new" {
+		return false;
+	}
+	if _, ok := f.pkg.info.Uses[id].(*types.Builtin); !ok {
+		return false;
+	}
+	return isCookie(f.pkg.info.TypeOf(call.Args[0]));
+}
+
+func addLiteralFields(fields cookieFields, lit *ast.CompositeLit) {
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				fields[key.Name] = kv.Value;
+			}
+		}
+	}
+}
+
+// isSet reports whether a cookie attribute is set to anything but a constant false
+// or the zero SameSite mode
+func isSet(f *File, fields cookieFields, name string) bool {
+	x, ok := fields[name];
+	if !ok {
+		return false;
+	}
+	if v := f.pkg.info.Types[x].Value; v != nil {
+		switch v.Kind() {
+		case constant.Bool:
+			return constant.BoolVal(v);
+		case constant.Int:
+			// http.SameSiteDefaultMode is 1, 0 is unset
+			n, _ := constant.Int64Val(v);
+			return n > 1;
+		}
+	}
+	return true;
+}
+
+// reportCookie reports the attributes missing from a cookie sent by call
+func reportCookie(f *File, call *ast.CallExpr, arg ast.Expr, fields cookieFields) {
+	kind, desc := "cookie", f.ASTString(arg)
+	if x, ok := fields["Name"]; ok {
+		if name, ok := stringValue(f, x); ok {
+			desc = name;
+			name = strings.ToLower(name);
+			for _, s := range sessionCookieNames {
+				if strings.Contains(name, s) {
+					kind = "session cookie";
+					break;
+				}
+			}
+		}
+	}
+	if cookieLiteral(f, arg) != nil && desc == f.ASTString(arg) {
+		// literals span lines, the position is enough
+		desc = "literal";
+	}
+	var missing []string
+	for _, attr := range []string{"Secure", "HttpOnly", "SameSite"} {
+		if !isSet(f, fields, attr) {
+			missing = append(missing, attr);
+		}
+	}
+	if mode, ok := fields["SameSite"].(*ast.SelectorExpr); ok && mode.Sel.Name == "SameSiteNoneMode" && !isSet(f, fields, "Secure") {
+		f.Reportf(call.Pos(), "%s %s with SameSite=None is not Secure", kind, desc);
+		missing = missing[1:];
+	}
+	if len(missing) > 0 {
+		f.Reportf(call.Pos(), "%s %s set without %s", kind, desc, strings.Join(missing, ", "));
+	}
+}
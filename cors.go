@@ -0,0 +1,221 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"strings"
+)
+
+func init() {
+	register("cors",
+		"this tests for CORS headers that reflect the request origin or allow any origin with credentials",
+		corsCheck,
+		funcDecl,
+		funcLit,
+		compositeLit,
+		callExpr)
+}
+
+// headerName returns the constant header name set by a Header Set or Add call
+func headerName(f *File, call *ast.CallExpr) string {
+	switch calleeName(f, call) {
+	case "(net/http.Header).Set", "(net/http.Header).Add":
+	default:
+		return "";
+	}
+	if len(call.Args) != 2 {
+		return "";
+	}
+	if v := f.pkg.info.Types[call.Args[0]].Value; v != nil && v.Kind() == constant.String {
+		return strings.ToLower(constant.StringVal(v));
+	}
+	return "";
+}
+
+// isOriginHeader is a taint source for the Origin header of a request
+func isOriginHeader(f *File, x ast.Expr) bool {
+	var key ast.Expr
+	var header ast.Expr
+	switch e := x.(type) {
+	case *ast.CallExpr:
+		// r.Header.Get("Origin")
+		if calleeName(f, e) != "(net/http.Header).Get" || len(e.Args) != 1 {
+			return false;
+		}
+		if sel, ok := e.Fun.(*ast.SelectorExpr); ok {
+			header, key = sel.X, e.Args[0];
+		}
+	case *ast.IndexExpr:
+		// r.Header["Origin"]
+		header, key = e.X, e.Index;
+	default:
+		return false;
+	}
+	sel, ok := header.(*ast.SelectorExpr);
+	if !ok || sel.Sel.Name != "Header" || !isNamedType(f.pkg.info.TypeOf(sel.X), "net/http", "Request") {
+		return false;
+	}
+	if v := f.pkg.info.Types[key].Value; v != nil && v.Kind() == constant.String {
+		return strings.EqualFold(constant.StringVal(v), "Origin");
+	}
+	return false;
+}
+
+func corsCheck(f *File, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		if n.Body != nil {
+			corsHeaderCheck(f, n.Body);
+		}
+	case *ast.FuncLit:
+		// http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {...})
+		if body := handlerBody(f, n); body != nil {
+			corsHeaderCheck(f, body);
+		}
+	case *ast.CompositeLit:
+		corsOptionsCheck(f, n);
+	case *ast.CallExpr:
+		corsHandlerCheck(f, n);
+	}
+}
+
+// corsHeaderCheck reports Access-Control-Allow-Origin headers set
+// from the request Origin, or to * alongside allowed credentials
+func corsHeaderCheck(f *File, body *ast.BlockStmt) {
+	var origins []*ast.CallExpr
+	var credentials *ast.CallExpr
+	inspectOwn(f, body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			switch headerName(f, call) {
+			case "access-control-allow-origin":
+				origins = append(origins, call);
+			case "access-control-allow-credentials":
+				if v, ok := stringValue(f, call.Args[1]); ok && strings.EqualFold(v, "true") {
+					credentials = call;
+				}
+			}
+		}
+		return true;
+	})
+	if len(origins) == 0 {
+		return;
+	}
+	t := newTaint(f, body, isOriginHeader);
+	for _, call := range origins {
+		value := call.Args[1]
+		reason := ""
+		if t.isUnchecked(value) {
+			reason = "reflects the request Origin";
+		} else if v, ok := stringValue(f, value); ok && v == "*" && credentials != nil {
+			reason = "is *";
+		}
+		if reason == "" {
+			continue
+		}
+		if credentials != nil {
+			f.Reportf(call.Pos(), "Access-Control-Allow-Origin %s with credentials allowed at %s", reason, f.loc(credentials.Pos()));
+			f.Reportf(credentials.Pos(), "Access-Control-Allow-Credentials set while Access-Control-Allow-Origin %s at %s", reason, f.loc(call.Pos()));
+		} else {
+			f.Reportf(call.Pos(), "Access-Control-Allow-Origin %s", reason);
+		}
+	}
+}
+
+// corsOptionsCheck reports option structs of CORS middleware
+// such as github.com/rs/cors and github.com/gin-contrib/cors
+// that allow any origin together with credentials
+func corsOptionsCheck(f *File, lit *ast.CompositeLit) {
+	if !strings.HasSuffix(importedPath(f, lit.Type), "cors") {
+		return;
+	}
+	var anyOrigin, credentials ast.Node
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr);
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident);
+		if !ok {
+			continue
+		}
+		switch key.Name {
+		case "AllowedOrigins", "AllowOrigins":
+			if containsWildcard(f, kv.Value) {
+				anyOrigin = kv;
+			}
+		case "AllowAllOrigins":
+			if isTrue(f, kv.Value) {
+				anyOrigin = kv;
+			}
+		case "AllowOriginFunc", "AllowOriginRequestFunc":
+			// func(origin string) bool { return true }
+			if fn, ok := kv.Value.(*ast.FuncLit); ok && len(fn.Body.List) == 1 {
+				if ret, ok := fn.Body.List[0].(*ast.ReturnStmt); ok && len(ret.Results) == 1 && isTrue(f, ret.Results[0]) {
+					anyOrigin = kv;
+				}
+			}
+		case "AllowCredentials":
+			if isTrue(f, kv.Value) {
+				credentials = kv;
+			}
+		}
+	}
+	if anyOrigin != nil && credentials != nil {
+		f.Reportf(anyOrigin.Pos(), "CORS options allow any origin with credentials allowed at %s", f.loc(credentials.Pos()));
+		f.Reportf(credentials.Pos(), "CORS options allow credentials for any origin allowed at %s", f.loc(anyOrigin.Pos()));
+	}
+}
+
+// corsHandlerCheck reports gorilla/handlers.CORS called with
+// AllowedOrigins([]string{"*"}) and AllowCredentials()
+func corsHandlerCheck(f *File, call *ast.CallExpr) {
+	if getFuncName(call) != "CORS" || !strings.HasSuffix(importedPath(f, call.Fun), "gorilla/handlers") {
+		return;
+	}
+	var anyOrigin, credentials ast.Node
+	for _, arg := range call.Args {
+		opt, ok := arg.(*ast.CallExpr);
+		if !ok {
+			continue
+		}
+		switch getFuncName(opt) {
+		case "AllowedOrigins":
+			if len(opt.Args) == 1 && containsWildcard(f, opt.Args[0]) {
+				anyOrigin = opt;
+			}
+		case "AllowCredentials":
+			credentials = opt;
+		}
+	}
+	if anyOrigin != nil && credentials != nil {
+		f.Reportf(anyOrigin.Pos(), "CORS handler allows any origin with credentials allowed at %s", f.loc(credentials.Pos()));
+		f.Reportf(credentials.Pos(), "CORS handler allows credentials for any origin allowed at %s", f.loc(anyOrigin.Pos()));
+	}
+}
+
+// containsWildcard reports whether a []string literal holds "*"
+func containsWildcard(f *File, x ast.Expr) bool {
+	lit, ok := x.(*ast.CompositeLit);
+	if !ok {
+		return false;
+	}
+	for _, elt := range lit.Elts {
+		if v, ok := stringValue(f, elt); ok && v == "*" {
+			return true;
+		}
+	}
+	return false;
+}
+
+// isTrue reports whether x is the constant true
+func isTrue(f *File, x ast.Expr) bool {
+	if v := f.pkg.info.Types[x].Value; v != nil && v.Kind() == constant.Bool {
+		return constant.BoolVal(v);
+	}
+	// the type checker has no value when the package failed to import
+	id, ok := x.(*ast.Ident);
+	return ok && id.Name == "true";
+}
@@ -0,0 +1,256 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("defer",
+		"this tests for defers in loops, before error checks, dropping write errors or evaluating arguments early",
+		deferCheck,
+		funcDecl,
+		funcLit)
+}
+
+// eagerCalls are calls that give the wrong answer as the argument of a deferred call
+// because they are evaluated when the defer statement runs
+func eagerCalls() map[string]string {
+	calls := make(map[string]string)
+	calls["time.Since"]	= "measures no time"
+	calls["time.Now"]	= "is the start time, not the end"
+	calls["recover"]	= "always returns nil unless called by the deferred function itself"
+
+	return calls;
+}
+
+// writers are opened for writing, closing them flushes data and can fail
+func writers() map[string]bool {
+	calls := make(map[string]bool)
+	calls["os.Create"]			= true
+	calls["os.CreateTemp"]			= true
+	calls["io/ioutil.TempFile"]		= true
+	calls["compress/gzip.NewWriter"]	= true
+	calls["compress/gzip.NewWriterLevel"]	= true
+	calls["compress/zlib.NewWriter"]	= true
+	calls["archive/zip.NewWriter"]		= true
+	calls["archive/tar.NewWriter"]		= true
+
+	return calls;
+}
+
+func deferCheck(f *File, node ast.Node) {
+	var body *ast.BlockStmt
+	switch fun := node.(type) {
+	case *ast.FuncDecl:
+		body = fun.Body;
+	case *ast.FuncLit:
+		body = fun.Body;
+	}
+	if body == nil {
+		return;
+	}
+	deferLoopCheck(f, body, false);
+	deferBeforeCheck(f, body);
+	deferWriteCheck(f, body);
+	deferArgsCheck(f, body);
+}
+
+// deferLoopCheck reports defers that run once per iteration
+// but are only released when the function returns
+func deferLoopCheck(f *File, node ast.Node, inLoop bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			// a closure called in the loop has its own defers
+			return false;
+		case *ast.ForStmt:
+			deferLoopCheck(f, x.Body, true);
+			return false;
+		case *ast.RangeStmt:
+			deferLoopCheck(f, x.Body, true);
+			return false;
+		case *ast.BlockStmt:
+			// defer mu.Unlock() followed by return runs once
+			if inLoop && len(x.List) > 0 {
+				if _, ok := x.List[len(x.List)-1].(*ast.ReturnStmt); ok {
+					deferLoopCheck(f, x, false);
+					return false;
+				}
+			}
+		case *ast.DeferStmt:
+			if inLoop {
+				f.Reportf(x.Pos(), "defer in a loop only runs when the function returns, resources are held for every iteration: %s", f.ASTString(x.Call));
+			}
+		}
+		return true;
+	})
+}
+
+// deferBeforeCheck reports defer x.Close() placed between the call
+// returning x and the check of its error, where x may be nil
+func deferBeforeCheck(f *File, body *ast.BlockStmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false;
+		}
+		block, ok := n.(*ast.BlockStmt);
+		if !ok {
+			return true;
+		}
+		for i, stmt := range block.List {
+			assign, ok := stmt.(*ast.AssignStmt);
+			if !ok || len(assign.Rhs) != 1 {
+				continue
+			}
+			call, ok := assign.Rhs[0].(*ast.CallExpr);
+			if !ok {
+				continue
+			}
+			index := returnsError(f, call)
+			if index < 0 || index >= len(assign.Lhs) || len(assign.Lhs) < 2 {
+				continue
+			}
+			errObj := f.pkg.info.ObjectOf(rootIdent(assign.Lhs[index]));
+			for _, next := range block.List[i+1:] {
+				if errObj == nil || mentions(f, next, errObj) {
+					break;
+				}
+				d, ok := next.(*ast.DeferStmt);
+				if !ok {
+					continue
+				}
+				for j, lhs := range assign.Lhs {
+					if j != index && strings.HasPrefix(f.ASTString(d.Call.Fun), f.ASTString(lhs) + ".") {
+						f.ReportOncef("unchecked error", d.Pos(), "%s deferred before the error from %s is checked", f.ASTString(d.Call), f.ASTString(call.Fun));
+					}
+				}
+			}
+		}
+		return true;
+	})
+}
+
+// mentions reports whether node refers to obj
+func mentions(f *File, node ast.Node, obj types.Object) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && f.pkg.info.ObjectOf(id) == obj {
+			found = true;
+		}
+		return !found;
+	})
+	return found;
+}
+
+// isWriter reports whether call opens something for writing
+func isWriter(f *File, call *ast.CallExpr) bool {
+	name := calleeName(f, call);
+	if writers()[name] {
+		return true;
+	}
+	if name == "os.OpenFile" && len(call.Args) > 1 {
+		for _, flag := range []string{"O_WRONLY", "O_RDWR", "O_APPEND", "O_CREATE"} {
+			if hasFlag(call.Args[1], flag) {
+				return true;
+			}
+		}
+	}
+	return false;
+}
+
+// deferWriteCheck reports defer x.Close() on files and writers opened for writing,
+// which throws away the error from flushing the last writes
+func deferWriteCheck(f *File, body *ast.BlockStmt) {
+	written := make(map[types.Object]bool)
+	// Close calls whose result is discarded, any other Close is checked
+	// like return f.Close() after the deferred one
+	discarded := make(map[*ast.CallExpr]bool)
+	var closes []*ast.CallExpr
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if len(x.Rhs) != 1 {
+				break;
+			}
+			if call, ok := x.Rhs[0].(*ast.CallExpr); ok && isWriter(f, call) {
+				if obj := f.pkg.info.ObjectOf(rootIdent(x.Lhs[0])); obj != nil {
+					written[obj] = true;
+				}
+			}
+		case *ast.ExprStmt:
+			if call, ok := x.X.(*ast.CallExpr); ok {
+				discarded[call] = true;
+			}
+		case *ast.DeferStmt:
+			discarded[x.Call] = true;
+		case *ast.GoStmt:
+			discarded[x.Call] = true;
+		case *ast.CallExpr:
+			if getFuncName(x) == "Close" {
+				closes = append(closes, x);
+			}
+		}
+		return true;
+	})
+	for _, call := range closes {
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && !discarded[call] {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				delete(written, f.pkg.info.ObjectOf(id));
+			}
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return false;
+		case *ast.DeferStmt:
+			sel, ok := x.Call.Fun.(*ast.SelectorExpr);
+			if !ok || sel.Sel.Name != "Close" {
+				return true;
+			}
+			if id, ok := sel.X.(*ast.Ident); ok && written[f.pkg.info.ObjectOf(id)] {
+				f.Reportf(x.Pos(), "deferred %s drops the error of the final write, check the error of Close", f.ASTString(x.Call));
+			}
+		}
+		return true;
+	})
+}
+
+// deferArgsCheck reports deferred calls whose arguments are computed
+// when the defer statement runs rather than when the call does
+func deferArgsCheck(f *File, body *ast.BlockStmt) {
+	calls := eagerCalls();
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return false;
+		case *ast.DeferStmt:
+			for _, arg := range x.Call.Args {
+				ast.Inspect(arg, func(m ast.Node) bool {
+					if _, ok := m.(*ast.FuncLit); ok {
+						return false;
+					}
+					call, ok := m.(*ast.CallExpr);
+					if !ok {
+						return true;
+					}
+					name := calleeName(f, call);
+					// builtins such as recover have no types.Func
+					if id, ok := call.Fun.(*ast.Ident); ok && name == "" {
+						name = id.Name;
+					}
+					if why, ok := calls[name]; ok {
+						f.Reportf(call.Pos(), "%s is evaluated when the defer runs and %s, wrap the call in a func literal", f.ASTString(call), why);
+					}
+					return true;
+				})
+			}
+		}
+		return true;
+	})
+}
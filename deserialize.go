@@ -0,0 +1,181 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("deserialize",
+		"this tests for unsafe decoding of untrusted data",
+		deserializeCheck,
+		funcDecl,
+		assignStmt,
+		callExpr)
+}
+
+// isInterfaceTarget reports whether a decode target is, or points to, an interface
+func isInterfaceTarget(t types.Type) bool {
+	if t == nil {
+		return false;
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem();
+	}
+	_, ok := t.Underlying().(*types.Interface);
+	return ok;
+}
+
+// isGenericMap reports whether a decode target is, or points to, a map of interface values
+func isGenericMap(t types.Type) bool {
+	if t == nil {
+		return false;
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem();
+	}
+	m, ok := t.Underlying().(*types.Map);
+	return ok && isInterfaceTarget(m.Elem());
+}
+
+func deserializeCheck(f *File, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		if n.Body != nil {
+			gobCheck(f, n.Body);
+			assertionCheck(f, n.Body);
+		}
+	case *ast.AssignStmt:
+		xmlDecoderCheck(f, n);
+	case *ast.CallExpr:
+		yamlCheck(f, n);
+	}
+}
+
+// gobCheck reports gob decoders reading network input into interfaces,
+// which lets the sender choose any registered type
+func gobCheck(f *File, body *ast.BlockStmt) {
+	// a limited reader is still network input here
+	t := newTaint(f, body, isUnboundedReader);
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok || calleeName(f, call) != "(*encoding/gob.Decoder).Decode" || len(call.Args) != 1 {
+			return true;
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr);
+		if !ok {
+			return true;
+		}
+		if isInterfaceTarget(f.pkg.info.TypeOf(call.Args[0])) && t.isTainted(sel.X) {
+			f.Reportf(call.Pos(), "gob decoding of network input into an interface: %s", f.ASTString(call));
+		}
+		return true;
+	})
+}
+
+// xmlDecoderCheck reports d.Strict = false and custom d.Entity maps on xml decoders
+func xmlDecoderCheck(f *File, assign *ast.AssignStmt) {
+	for i, lhs := range assign.Lhs {
+		sel, ok := lhs.(*ast.SelectorExpr);
+		if !ok || i >= len(assign.Rhs) || !isNamedType(f.pkg.info.TypeOf(sel.X), "encoding/xml", "Decoder") {
+			continue
+		}
+		switch sel.Sel.Name {
+		case "Strict":
+			if v := f.pkg.info.Types[assign.Rhs[i]].Value; v != nil && v.String() == "false" {
+				f.Reportf(assign.Pos(), "xml decoder in non-strict mode: %s", f.ASTString(lhs));
+			}
+		case "Entity":
+			f.Reportf(assign.Pos(), "xml decoder with custom entity map: %s = %s", f.ASTString(lhs), f.ASTString(assign.Rhs[i]));
+		}
+	}
+}
+
+// assertionCheck reports type assertions without the comma-ok form on
+// values from json decoded into generic maps or interfaces
+func assertionCheck(f *File, body *ast.BlockStmt) {
+	decoded := make(map[types.Object]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		var target ast.Expr
+		switch calleeName(f, call) {
+		case "encoding/json.Unmarshal":
+			if len(call.Args) == 2 {
+				target = call.Args[1];
+			}
+		case "(*encoding/json.Decoder).Decode":
+			if len(call.Args) == 1 {
+				target = call.Args[0];
+			}
+		}
+		if target == nil {
+			return true;
+		}
+		typ := f.pkg.info.TypeOf(target);
+		if isGenericMap(typ) || isInterfaceTarget(typ) {
+			if id := rootIdent(target); id != nil {
+				decoded[f.pkg.info.ObjectOf(id)] = true;
+			}
+		}
+		return true;
+	})
+	if len(decoded) == 0 {
+		return;
+	}
+	source := func(f *File, x ast.Expr) bool {
+		id, ok := x.(*ast.Ident);
+		return ok && decoded[f.pkg.info.ObjectOf(id)];
+	}
+	t := newTaint(f, body, source);
+
+	// v, ok := x.(T) is checked
+	checked := make(map[*ast.TypeAssertExpr]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if len(x.Lhs) == 2 && len(x.Rhs) == 1 {
+				if assert, ok := ast.Unparen(x.Rhs[0]).(*ast.TypeAssertExpr); ok {
+					checked[assert] = true;
+				}
+			}
+		case *ast.ValueSpec:
+			if len(x.Names) == 2 && len(x.Values) == 1 {
+				if assert, ok := ast.Unparen(x.Values[0]).(*ast.TypeAssertExpr); ok {
+					checked[assert] = true;
+				}
+			}
+		}
+		return true;
+	})
+	ast.Inspect(body, func(n ast.Node) bool {
+		assert, ok := n.(*ast.TypeAssertExpr);
+		// a nil Type is a type switch, which is checked
+		if !ok || assert.Type == nil || checked[assert] {
+			return true;
+		}
+		if t.isTainted(assert.X) {
+			f.ReportOncef("type assertion", assert.Pos(), "unchecked type assertion on decoded JSON, use the comma-ok form: %s", f.ASTString(assert));
+		}
+		return true;
+	})
+}
+
+// yamlCheck reports YAML unmarshalled into an interface
+func yamlCheck(f *File, call *ast.CallExpr) {
+	if getFuncName(call) != "Unmarshal" || len(call.Args) < 2 {
+		return;
+	}
+	if !strings.Contains(importedPath(f, call.Fun), "yaml") {
+		return;
+	}
+	if isInterfaceTarget(f.pkg.info.TypeOf(call.Args[1])) {
+		f.Reportf(call.Pos(), "YAML unmarshalled into an interface: %s", f.ASTString(call));
+	}
+}
@@ -15,24 +15,33 @@ func init() {
 		exprStmt)
 }
 
-func returnsError(f *File, call *ast.CallExpr) int {
+// resultIndex returns the index of the first result of call
+// whose type matches, or -1
+func resultIndex(f *File, call *ast.CallExpr, match func(types.Type) bool) int {
 	if typeValue := f.pkg.info.TypeOf(call); typeValue != nil {
 		switch t := typeValue.(type) {
 		case *types.Tuple:
 			for i := 0; i < t.Len(); i++ {
 				variable := t.At(i)
-				if variable != nil && variable.Type().String() == "error" {
+				if variable != nil && match(variable.Type()) {
 					return i;
 				}
 			}
-		case *types.Named:
-			if t.String() == "error"{
+		default:
+			if match(t) {
 				return 0;
 			}
 		}	
 	}
 	return -1;
 }
+
+func returnsError(f *File, call *ast.CallExpr) int {
+	return resultIndex(f, call, func(t types.Type) bool {
+		_, named := t.(*types.Named);
+		return named && t.String() == "error";
+	})
+}
  
 // Possibly check if anything returns an error before running the test
 // however, this may take roughly the same amount of effort as
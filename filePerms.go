@@ -0,0 +1,133 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/constant"
+	"strconv"
+	"strings"
+)
+
+var (
+	permLimits = flag.String("perms.limits",
+		"os.OpenFile=0644,os.WriteFile=0644,io/ioutil.WriteFile=0644,os.Mkdir=0755,os.MkdirAll=0755,os.Chmod=0755,(*os.File).Chmod=0755",
+		"comma separated call=mode pairs of the most permissive mode allowed per call")
+	permSecret = flag.String("perms.secret", "0600", "most permissive mode allowed for files that look like they hold secrets, directories also get the owner search bit")
+)
+
+func init() {
+	register("filePerms",
+		"this tests for files and directories created with insecure permissions",
+		filePermsCheck,
+		callExpr)
+}
+
+// permCalls maps calls setting a file mode to the index of the mode argument
+func permCalls() map[string]int {
+	calls := make(map[string]int)
+	calls["os.OpenFile"]		= 2
+	calls["os.WriteFile"]		= 2
+	calls["io/ioutil.WriteFile"]	= 2
+	calls["os.Mkdir"]		= 1
+	calls["os.MkdirAll"]		= 1
+	calls["os.Chmod"]		= 1
+	calls["(*os.File).Chmod"]	= 0
+
+	return calls;
+}
+
+// parsed -perms.limits, built on first use after flags are parsed
+var permLimitMap map[string]uint64
+
+// permLimit returns the most permissive mode allowed for a call
+func permLimit(name string) (uint64, bool) {
+	if permLimitMap == nil {
+		permLimitMap = make(map[string]uint64);
+		for _, pair := range strings.Split(*permLimits, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2);
+			if len(kv) != 2 {
+				continue
+			}
+			mode, err := strconv.ParseUint(kv[1], 0, 32);
+			if err != nil {
+				warnf("bad mode in -perms.limits %s: %s", pair, err);
+				continue
+			}
+			permLimitMap[kv[0]] = mode;
+		}
+	}
+	mode, ok := permLimitMap[name];
+	return mode, ok;
+}
+
+// mentionsSecret reports whether an expression, usually a path, looks like it holds a secret
+func mentionsSecret(f *File, x ast.Expr) bool {
+	text := strings.ToLower(f.ASTString(x));
+	for _, ext := range []string{".pem", ".key", "id_rsa", "id_ed25519", ".p12", ".pfx"} {
+		if strings.Contains(text, ext) {
+			return true;
+		}
+	}
+	return isSecretName(text);
+}
+
+func filePermsCheck(f *File, node ast.Node) {
+	call, ok := node.(*ast.CallExpr);
+	if !ok {
+		return;
+	}
+	name := calleeName(f, call);
+	if name == "syscall.Umask" && len(call.Args) == 1 {
+		// a umask that lets others write
+		if v := f.pkg.info.Types[call.Args[0]].Value; v != nil {
+			if mask, ok := constant.Uint64Val(constant.ToInt(v)); ok && mask&0002 == 0 {
+				f.Reportf(call.Pos(), "umask does not remove world write permission: %s", f.ASTString(call));
+			}
+		}
+		return;
+	}
+	index, ok := permCalls()[name];
+	if !ok || index >= len(call.Args) {
+		return;
+	}
+	v := f.pkg.info.Types[call.Args[index]].Value
+	if v == nil {
+		// not a constant, nothing to say
+		return;
+	}
+	mode, ok := constant.Uint64Val(constant.ToInt(v));
+	if !ok {
+		return;
+	}
+	mode &= 0777;
+	if mode&0002 != 0 {
+		f.Reportf(call.Pos(), "world writable permissions %#o: %s", mode, f.ASTString(call));
+		return;
+	}
+	// files holding secrets should not be readable by others
+	if len(call.Args) > 1 && mentionsSecret(f, call.Args[0]) {
+		if secret, err := strconv.ParseUint(*permSecret, 0, 32); err == nil {
+			what := "file";
+			switch name {
+			case "os.OpenFile", "os.WriteFile", "io/ioutil.WriteFile":
+			default:
+				// directories, and paths that may be one, need the owner search bit
+				secret |= 0100;
+				what = "directory";
+				if name == "os.Chmod" {
+					what = "path";
+				}
+			}
+			if mode&^secret != 0 {
+				f.Reportf(call.Pos(), "%s that may hold secrets created with permissions %#o, expected at most %#o: %s", what, mode, secret, f.ASTString(call));
+				return;
+			}
+		}
+	}
+	if limit, ok := permLimit(name); ok && mode&^limit != 0 {
+		f.Reportf(call.Pos(), "permissions %#o are wider than %#o: %s", mode, limit, f.ASTString(call));
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("format",
+		"this tests for non-constant format strings and format verbs that do not match their arguments",
+		formatCheck,
+		callExpr)
+}
+
+// printf style functions mapped to the index of their format argument
+func printfFuncs() map[string]int {
+	calls := make(map[string]int)
+	for _, name := range []string{"Printf", "Sprintf", "Errorf"} {
+		calls["fmt."+name] = 0;
+	}
+	calls["fmt.Fprintf"] = 1;
+	calls["fmt.Appendf"] = 1;
+	for _, name := range []string{"Printf", "Fatalf", "Panicf"} {
+		calls["log."+name] = 0;
+		calls["(*log.Logger)."+name] = 0;
+	}
+	for _, name := range []string{"Errorf", "Fatalf", "Logf", "Skipf"} {
+		calls["(*testing.common)."+name] = 0;
+	}
+
+	return calls;
+}
+
+// printf wrappers found in each package, computed once per package
+var printfWrappers = make(map[*Package]map[types.Object]int)
+
+// findPrintfWrappers returns the functions of a package that take a format
+// and ...interface{} and forward both to a printf style function,
+// like File.Reportf in this tool
+func findPrintfWrappers(f *File) map[types.Object]int {
+	if wrappers, ok := printfWrappers[f.pkg]; ok {
+		return wrappers;
+	}
+	wrappers := make(map[types.Object]int)
+	printfWrappers[f.pkg] = wrappers;
+	known := printfFuncs();
+
+	// wrappers of wrappers are found on later passes
+	for changed := true; changed; {
+		changed = false;
+		for _, file := range f.pkg.files {
+			for _, decl := range file.Decls {
+				fun, ok := decl.(*ast.FuncDecl);
+				if !ok || fun.Body == nil {
+					continue
+				}
+				obj := f.pkg.info.Defs[fun.Name]
+				if obj == nil {
+					continue
+				}
+				if _, done := wrappers[obj]; done {
+					continue
+				}
+				if index := forwardsFormat(f, fun, obj, known, wrappers); index >= 0 {
+					wrappers[obj] = index;
+					changed = true;
+				}
+			}
+		}
+	}
+	return wrappers;
+}
+
+// forwardsFormat returns the index of the format parameter of fun if it
+// passes it and its variadic arguments on to a printf style call, or -1
+func forwardsFormat(f *File, fun *ast.FuncDecl, obj types.Object, known map[string]int, wrappers map[types.Object]int) int {
+	sig, ok := obj.Type().(*types.Signature);
+	if !ok || !sig.Variadic() || sig.Params().Len() < 2 {
+		return -1;
+	}
+	params := sig.Params()
+	args := params.At(params.Len() - 1)
+	if slice, ok := args.Type().(*types.Slice); !ok || !isEmptyInterface(slice.Elem()) {
+		return -1;
+	}
+	format := params.At(params.Len() - 2)
+	if basic, ok := format.Type().Underlying().(*types.Basic); !ok || basic.Info()&types.IsString == 0 {
+		return -1;
+	}
+	forwards := false
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok || !call.Ellipsis.IsValid() || forwards {
+			return !forwards;
+		}
+		index, ok := printfIndex(f, call, known, wrappers);
+		if !ok || index+1 >= len(call.Args) {
+			return true;
+		}
+		// log.Printf(prefix+format, args...) counts as forwarding
+		usesFormat := false
+		ast.Inspect(call.Args[index], func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && f.pkg.info.Uses[id] == format {
+				usesFormat = true;
+			}
+			return !usesFormat;
+		})
+		last, ok := call.Args[len(call.Args)-1].(*ast.Ident);
+		if ok && usesFormat && f.pkg.info.Uses[last] == args {
+			forwards = true;
+		}
+		return true;
+	})
+	if forwards {
+		return params.Len() - 2;
+	}
+	return -1;
+}
+
+// isEmptyInterface reports whether t is interface{} or any
+func isEmptyInterface(t types.Type) bool {
+	iface, ok := t.Underlying().(*types.Interface);
+	return ok && iface.NumMethods() == 0;
+}
+
+// printfIndex returns the format argument index of a call to a printf style function or wrapper
+func printfIndex(f *File, call *ast.CallExpr, known map[string]int, wrappers map[types.Object]int) (int, bool) {
+	fn := getCallee(f, call);
+	if fn == nil {
+		return 0, false;
+	}
+	if index, ok := known[fn.FullName()]; ok {
+		return index, true;
+	}
+	// methods of generic or embedded types resolve to their origin
+	index, ok := wrappers[fn.Origin()];
+	return index, ok;
+}
+
+func formatCheck(f *File, node ast.Node) {
+	call, ok := node.(*ast.CallExpr);
+	if !ok {
+		return;
+	}
+	index, ok := printfIndex(f, call, printfFuncs(), findPrintfWrappers(f));
+	if !ok || index >= len(call.Args) {
+		return;
+	}
+	// forwarding format, args... is checked where the wrapper is called
+	if call.Ellipsis.IsValid() {
+		return;
+	}
+	format := call.Args[index]
+	value, ok := formatValue(f, format);
+	if !ok {
+		f.Reportf(call.Pos(), "non-constant format string in call to %s: %s", getFuncName(call), f.ASTString(format));
+		return;
+	}
+	verbs, ok := parseVerbs(value);
+	if !ok {
+		// explicit argument indexes are not checked
+		return;
+	}
+	args := call.Args[index+1:]
+	if len(verbs) != len(args) {
+		f.Reportf(call.Pos(), "%s format has %d verbs but %d arguments: %s", getFuncName(call), len(verbs), len(args), f.ASTString(call));
+		return;
+	}
+	for i, verb := range verbs {
+		if verb == '*' {
+			if basic := integerType(f.pkg.info.TypeOf(args[i])); basic == nil {
+				f.Reportf(args[i].Pos(), "%s width or precision * needs an int: %s", getFuncName(call), f.ASTString(args[i]));
+			}
+			continue
+		}
+		if !verbMatches(f.pkg.info.TypeOf(args[i]), verb) {
+			f.Reportf(args[i].Pos(), "%s verb %%%c does not match argument of type %s: %s", getFuncName(call), verb, f.pkg.info.TypeOf(args[i]), f.ASTString(args[i]));
+		}
+	}
+}
+
+// formatValue returns the constant value of a format, or of a local
+// variable that is only ever assigned one constant string
+func formatValue(f *File, x ast.Expr) (string, bool) {
+	if v := f.pkg.info.Types[x].Value; v != nil && v.Kind() == constant.String {
+		return constant.StringVal(v), true;
+	}
+	id, ok := ast.Unparen(x).(*ast.Ident);
+	if !ok {
+		return "", false;
+	}
+	obj, ok := f.pkg.info.Uses[id].(*types.Var);
+	if !ok || obj.Parent() == nil || obj.Parent() == obj.Pkg().Scope() {
+		// parameters, fields and package variables can change elsewhere
+		return "", false;
+	}
+	value, assigned, constantOnly := "", 0, true
+	ast.Inspect(f.file, func(n ast.Node) bool {
+		var lhs []ast.Expr
+		var rhs []ast.Expr
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			lhs, rhs = x.Lhs, x.Rhs;
+		case *ast.ValueSpec:
+			for _, name := range x.Names {
+				lhs = append(lhs, name);
+			}
+			rhs = x.Values;
+		case *ast.UnaryExpr:
+			// &format may be written through
+			if id, ok := x.X.(*ast.Ident); ok && f.pkg.info.ObjectOf(id) == obj {
+				constantOnly = false;
+			}
+		}
+		for i, l := range lhs {
+			if id, ok := l.(*ast.Ident); !ok || f.pkg.info.ObjectOf(id) != obj {
+				continue
+			}
+			assigned++;
+			if len(lhs) != len(rhs) {
+				constantOnly = false;
+				continue
+			}
+			v := f.pkg.info.Types[rhs[i]].Value
+			if v == nil || v.Kind() != constant.String {
+				constantOnly = false;
+				continue
+			}
+			value = constant.StringVal(v);
+		}
+		return constantOnly;
+	})
+	return value, constantOnly && assigned == 1;
+}
+
+// parseVerbs returns the verb consuming each argument, * for widths,
+// it returns false if the format uses explicit argument indexes
+func parseVerbs(format string) ([]rune, bool) {
+	var verbs []rune
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++;
+		for ; i < len(format); i++ {
+			c := format[i]
+			if c == '[' {
+				return nil, false;
+			}
+			if c == '*' {
+				verbs = append(verbs, '*');
+				continue
+			}
+			if strings.IndexByte("+-# 0123456789.", c) < 0 {
+				break;
+			}
+		}
+		if i >= len(format) {
+			break;
+		}
+		if format[i] != '%' {
+			verbs = append(verbs, rune(format[i]));
+		}
+	}
+	return verbs, true;
+}
+
+// hasMethod reports whether t or *t has the named method
+func hasMethod(t types.Type, name string) bool {
+	for _, typ := range []types.Type{t, types.NewPointer(t)} {
+		if obj, _, _ := types.LookupFieldOrMethod(typ, true, nil, name); obj != nil {
+			if _, ok := obj.(*types.Func); ok {
+				return true;
+			}
+		}
+	}
+	return false;
+}
+
+// verbMatches reports whether an argument of type t can be printed with verb
+func verbMatches(t types.Type, verb rune) bool {
+	return verbMatchesIn(t, verb, true, make(map[types.Type]bool));
+}
+
+// verbMatchesIn is verbMatches for t inside an argument, slices, arrays, maps
+// and structs print element by element and match when their elements do,
+// as in go vet's printf check, pointers to them only at the top level
+func verbMatchesIn(t types.Type, verb rune, top bool, seen map[types.Type]bool) bool {
+	if t == nil || seen[t] {
+		return true;
+	}
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		// the dynamic type is unknown
+		return true;
+	}
+	if hasMethod(t, "Format") {
+		return true;
+	}
+	stringer := hasMethod(t, "String") || hasMethod(t, "Error")
+	var info types.BasicInfo
+	if basic, ok := t.Underlying().(*types.Basic); ok {
+		info = basic.Info();
+	}
+	bytes := false
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		bytes = isByte(u.Elem());
+	case *types.Array:
+		bytes = isByte(u.Elem());
+	}
+	if verb != 'p' && verb != 'T' && !bytes && !stringer {
+		seen[t] = true;
+		elems := func(ts ...types.Type) bool {
+			for _, e := range ts {
+				if !verbMatchesIn(e, verb, false, seen) {
+					return false;
+				}
+			}
+			return true;
+		}
+		switch u := t.Underlying().(type) {
+		case *types.Slice:
+			return elems(u.Elem());
+		case *types.Array:
+			return elems(u.Elem());
+		case *types.Map:
+			return elems(u.Key(), u.Elem());
+		case *types.Struct:
+			for i := 0; i < u.NumFields(); i++ {
+				if !elems(u.Field(i).Type()) {
+					return false;
+				}
+			}
+			return true;
+		case *types.Pointer:
+			switch u.Elem().Underlying().(type) {
+			case *types.Struct, *types.Array, *types.Slice, *types.Map:
+				if top {
+					return elems(u.Elem());
+				}
+			}
+		}
+	}
+	pointer := false
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Chan, *types.Map, *types.Signature, *types.Slice:
+		pointer = true;
+	}
+	if basic, ok := t.Underlying().(*types.Basic); ok && basic.Kind() == types.UnsafePointer {
+		pointer = true;
+	}
+
+	switch verb {
+	case 'v', 'T':
+		return true;
+	case 't':
+		return info&types.IsBoolean != 0;
+	case 'd', 'b', 'o', 'O', 'c', 'U':
+		return info&types.IsInteger != 0 || (verb == 'd' && pointer) || (verb == 'b' && info&types.IsFloat != 0);
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		return info&(types.IsFloat|types.IsComplex) != 0;
+	case 's':
+		return info&types.IsString != 0 || bytes || stringer;
+	case 'q':
+		return info&(types.IsString|types.IsInteger) != 0 || bytes || stringer;
+	case 'x', 'X':
+		return info&(types.IsString|types.IsInteger|types.IsFloat|types.IsComplex) != 0 || bytes || stringer || pointer;
+	case 'p':
+		return pointer;
+	}
+	// unknown verbs are reported by go vet
+	return true;
+}
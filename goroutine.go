@@ -0,0 +1,247 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+func init() {
+	register("goroutine",
+		"this tests for goroutines that leak or are started without bound",
+		goroutineCheck,
+		funcDecl,
+		funcLit)
+}
+
+func goroutineCheck(f *File, node ast.Node) {
+	if fun, ok := node.(*ast.FuncDecl); ok && fun.Body != nil {
+		unboundedGoCheck(f, fun.Body);
+		blockedSendCheck(f, fun.Body);
+	}
+	if body := handlerBody(f, node); body != nil {
+		handlerGoCheck(f, body);
+	}
+}
+
+// isLimiter reports whether a loop body bounds its goroutines
+// with a semaphore channel send or a semaphore Acquire
+func isLimiter(f *File, body ast.Node) bool {
+	limited := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			// a send inside the goroutine does not limit starting it
+			return false;
+		case *ast.SendStmt:
+			limited = true;
+		case *ast.CallExpr:
+			switch getFuncName(x) {
+			case "Acquire", "TryAcquire", "Wait":
+				limited = true;
+			}
+		}
+		return !limited;
+	})
+	return limited;
+}
+
+// unboundedGoCheck reports go statements in loops over request input
+// that have no semaphore or worker pool limiting them
+func unboundedGoCheck(f *File, body *ast.BlockStmt) {
+	t := newTaint(f, body, isRequestInput);
+	// errgroup.Group.SetLimit bounds every Go call
+	limit := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && getFuncName(call) == "SetLimit" {
+			limit = true;
+		}
+		return !limit;
+	})
+	if limit {
+		return;
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		var loopBody *ast.BlockStmt
+		driven := false
+		switch x := n.(type) {
+		case *ast.RangeStmt:
+			loopBody, driven = x.Body, t.isTainted(x.X);
+		case *ast.ForStmt:
+			loopBody, driven = x.Body, x.Cond != nil && t.isTainted(x.Cond);
+		default:
+			return true;
+		}
+		if !driven || isLimiter(f, loopBody) {
+			return true;
+		}
+		ast.Inspect(loopBody, func(n ast.Node) bool {
+			if _, ok := n.(*ast.FuncLit); ok {
+				return false;
+			}
+			if stmt, ok := n.(*ast.GoStmt); ok {
+				f.Reportf(stmt.Pos(), "goroutine started for each element of request input without a limit: %s", f.ASTString(stmt.Call.Fun));
+			}
+			return true;
+		})
+		return true;
+	})
+}
+
+// unbufferedChans returns the channels made without a buffer in a function body
+func unbufferedChans(f *File, body ast.Node) map[types.Object]bool {
+	chans := make(map[types.Object]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt);
+		if !ok || len(assign.Lhs) != len(assign.Rhs) {
+			return true;
+		}
+		for i, rhs := range assign.Rhs {
+			call, ok := rhs.(*ast.CallExpr);
+			if !ok || getFuncName(call) != "make" || len(call.Args) == 0 {
+				continue
+			}
+			// the type is unknown when an import failed
+			typ := f.pkg.info.TypeOf(call)
+			if typ == nil {
+				continue
+			}
+			if _, ok := typ.Underlying().(*types.Chan); !ok {
+				continue
+			}
+			unbuffered := len(call.Args) == 1
+			if len(call.Args) == 2 {
+				if v := f.pkg.info.Types[call.Args[1]].Value; v != nil && v.String() == "0" {
+					unbuffered = true;
+				}
+			}
+			if id, ok := assign.Lhs[i].(*ast.Ident); ok && unbuffered {
+				chans[f.pkg.info.ObjectOf(id)] = true;
+			}
+		}
+		return true;
+	})
+	return chans;
+}
+
+// chanObject returns the channel variable in a send or receive
+func chanObject(f *File, x ast.Expr) types.Object {
+	if id, ok := ast.Unparen(x).(*ast.Ident); ok {
+		return f.pkg.info.ObjectOf(id);
+	}
+	return nil;
+}
+
+// blockedSendCheck reports goroutines sending on an unbuffered channel
+// whose only receive is in a select that can take another case and return,
+// leaving the sender blocked forever
+func blockedSendCheck(f *File, body *ast.BlockStmt) {
+	chans := unbufferedChans(f, body);
+	if len(chans) == 0 {
+		return;
+	}
+	// channels received in a select with an alternative, like time.After
+	abandoned := make(map[types.Object]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectStmt);
+		if !ok || len(sel.Body.List) < 2 {
+			return true;
+		}
+		for _, clause := range sel.Body.List {
+			comm, ok := clause.(*ast.CommClause);
+			if !ok || comm.Comm == nil {
+				continue
+			}
+			var recv ast.Expr
+			switch stmt := comm.Comm.(type) {
+			case *ast.ExprStmt:
+				recv = stmt.X;
+			case *ast.AssignStmt:
+				if len(stmt.Rhs) == 1 {
+					recv = stmt.Rhs[0];
+				}
+			}
+			if u, ok := recv.(*ast.UnaryExpr); ok && u.Op == token.ARROW {
+				if obj := chanObject(f, u.X); chans[obj] {
+					abandoned[obj] = true;
+				}
+			}
+		}
+		return true;
+	})
+	if len(abandoned) == 0 {
+		return;
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.GoStmt);
+		if !ok {
+			return true;
+		}
+		lit, ok := stmt.Call.Fun.(*ast.FuncLit);
+		if !ok {
+			return true;
+		}
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			send, ok := n.(*ast.SendStmt);
+			if !ok {
+				return true;
+			}
+			if obj := chanObject(f, send.Chan); abandoned[obj] {
+				f.Reportf(send.Pos(), "goroutine may block forever sending on unbuffered channel %s after the receiver's select has moved on, make it buffered", obj.Name());
+			}
+			return true;
+		});
+		return false;
+	})
+}
+
+// handlerGoCheck reports goroutines in HTTP handlers that use the
+// handler's http.ResponseWriter or *http.Request, which are not valid
+// once the handler returns
+func handlerGoCheck(f *File, body *ast.BlockStmt) {
+	// the handler waits for its goroutines
+	waits := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && getFuncName(call) == "Wait" {
+			waits = true;
+		}
+		return !waits;
+	})
+	if waits {
+		return;
+	}
+	isHandlerValue := func(x ast.Expr) bool {
+		t := f.pkg.info.TypeOf(x)
+		return isNamedType(t, "net/http", "ResponseWriter") || isNamedType(t, "net/http", "Request");
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.FuncLit); ok && isHandler(f.pkg.info.TypeOf(lit)) {
+			// nested handlers are checked on their own
+			return false;
+		}
+		stmt, ok := n.(*ast.GoStmt);
+		if !ok {
+			return true;
+		}
+		used := ""
+		ast.Inspect(stmt.Call, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident);
+			if !ok || used != "" {
+				return used == "";
+			}
+			obj := f.pkg.info.Uses[id]
+			// declared outside the goroutine, so captured or passed in
+			if obj != nil && (obj.Pos() < stmt.Pos() || obj.Pos() > stmt.End()) && isHandlerValue(id) {
+				used = id.Name;
+			}
+			return true;
+		})
+		if used != "" {
+			f.Reportf(stmt.Pos(), "goroutine in HTTP handler uses %s which is invalid after the handler returns", used);
+		}
+		return false;
+	})
+}
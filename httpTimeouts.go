@@ -0,0 +1,177 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("httpTimeouts",
+		"this tests for HTTP servers and clients without timeouts or limits",
+		httpTimeoutsCheck,
+		fileNode)
+}
+
+// package level functions that serve HTTP with no timeouts at all
+func unlimitedServers() map[string]bool {
+	calls := make(map[string]bool)
+	calls["net/http.ListenAndServe"]	= true
+	calls["net/http.ListenAndServeTLS"]	= true
+	calls["net/http.Serve"]			= true
+	calls["net/http.ServeTLS"]		= true
+
+	return calls;
+}
+
+// package level functions that use http.DefaultClient
+func defaultClientCalls() map[string]bool {
+	calls := make(map[string]bool)
+	calls["net/http.Get"]		= true
+	calls["net/http.Head"]		= true
+	calls["net/http.Post"]		= true
+	calls["net/http.PostForm"]	= true
+
+	return calls;
+}
+
+// serverLimits are the http.Server fields that should be set,
+// any one name in a group is enough
+var serverLimits = [][]string{
+	{"ReadHeaderTimeout", "ReadTimeout"},
+	{"WriteTimeout"},
+	{"IdleTimeout"},
+	{"MaxHeaderBytes"},
+}
+
+// httpTimeoutsCheck reports http.Server and http.Client values
+// missing timeouts, fields set after construction are counted
+func httpTimeoutsCheck(f *File, node ast.Node) {
+	file, ok := node.(*ast.File);
+	if !ok {
+		return;
+	}
+	// fields assigned after construction, per variable
+	assigned := make(map[types.Object]map[string]bool)
+	// composite literals assigned to a variable
+	owner := make(map[*ast.CompositeLit]types.Object)
+	defaultTimeout := false
+	// the first request made with http.DefaultClient
+	var firstDefault ast.Expr
+
+	literal := func(x ast.Expr) *ast.CompositeLit {
+		if u, ok := x.(*ast.UnaryExpr); ok {
+			x = u.X;
+		}
+		lit, _ := x.(*ast.CompositeLit);
+		return lit;
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			for i, lhs := range x.Lhs {
+				if sel, ok := lhs.(*ast.SelectorExpr); ok {
+					// srv.ReadTimeout = 5 * time.Second
+					if isDefaultClient(f, sel.X) && sel.Sel.Name == "Timeout" {
+						defaultTimeout = true;
+					}
+					if id := rootIdent(sel.X); id != nil {
+						obj := f.pkg.info.ObjectOf(id);
+						if assigned[obj] == nil {
+							assigned[obj] = make(map[string]bool);
+						}
+						assigned[obj][sel.Sel.Name] = true;
+					}
+				}
+				if i < len(x.Rhs) {
+					if lit := literal(x.Rhs[i]); lit != nil {
+						if id, ok := lhs.(*ast.Ident); ok {
+							owner[lit] = f.pkg.info.ObjectOf(id);
+						}
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range x.Names {
+				if i < len(x.Values) {
+					if lit := literal(x.Values[i]); lit != nil {
+						owner[lit] = f.pkg.info.ObjectOf(name);
+					}
+				}
+			}
+		}
+		return true;
+	})
+
+	servers := unlimitedServers();
+	clients := defaultClientCalls();
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CompositeLit:
+			fields := make(map[string]bool)
+			for _, elt := range x.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok {
+						fields[key.Name] = true;
+					}
+				}
+			}
+			if obj := owner[x]; obj != nil {
+				for name := range assigned[obj] {
+					fields[name] = true;
+				}
+			}
+			typ := f.pkg.info.TypeOf(x)
+			switch {
+			case isNamedType(typ, "net/http", "Server"):
+				var missing []string
+				for _, group := range serverLimits {
+					set := false
+					for _, name := range group {
+						set = set || fields[name];
+					}
+					if !set {
+						missing = append(missing, group[0]);
+					}
+				}
+				if len(missing) > 0 {
+					f.Reportf(x.Pos(), "http.Server without %s", strings.Join(missing, ", "));
+				}
+			case isNamedType(typ, "net/http", "Client"):
+				if !fields["Timeout"] {
+					f.Reportf(x.Pos(), "http.Client without Timeout");
+				}
+			}
+		case *ast.CallExpr:
+			name := calleeName(f, x);
+			if servers[name] {
+				f.Reportf(x.Pos(), "HTTP server without timeouts, use an http.Server with timeouts set: %s", f.ASTString(x));
+			}
+			if clients[name] && firstDefault == nil {
+				firstDefault = x;
+			}
+		case *ast.SelectorExpr:
+			if isDefaultClient(f, x) && firstDefault == nil {
+				firstDefault = x;
+			}
+		}
+		return true;
+	})
+	// setting the timeout once fixes every use, so only the first is reported
+	if firstDefault != nil && !defaultTimeout {
+		f.Reportf(firstDefault.Pos(), "http.DefaultClient has no timeout, set http.DefaultClient.Timeout or use an http.Client with one: %s", f.ASTString(firstDefault));
+	}
+}
+
+// isDefaultClient reports whether x refers to http.DefaultClient
+func isDefaultClient(f *File, x ast.Expr) bool {
+	sel, ok := x.(*ast.SelectorExpr);
+	if !ok {
+		return false;
+	}
+	obj, ok := f.pkg.info.Uses[sel.Sel].(*types.Var);
+	return ok && obj.Pkg() != nil && obj.Pkg().Path() == "net/http" && obj.Name() == "DefaultClient";
+}
@@ -0,0 +1,221 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//  
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+func init() {
+	register("intConversion",
+		"check integer conversions that overflow, truncate or change sign",
+		intConversionCheck,
+		callExpr,
+		funcDecl)
+}
+
+// sizes of basic types on a 64 bit target
+var intSizes = types.SizesFor("gc", "amd64")
+
+func intConversionCheck(f *File, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.CallExpr:
+		intToStrCheck(f, n);
+		makeLenCheck(f, n);
+	case *ast.FuncDecl:
+		if n.Body != nil {
+			narrowingCheck(f, n.Body);
+			indexCheck(f, n.Body);
+		}
+	}
+}
+
+// conversion returns the target type and argument of a type conversion
+// or nil if the call is not a conversion
+func conversion(f *File, call *ast.CallExpr) (types.Type, ast.Expr) {
+	if len(call.Args) != 1 || !f.pkg.info.Types[call.Fun].IsType() {
+		return nil, nil;
+	}
+	return f.pkg.info.TypeOf(call.Fun), call.Args[0];
+}
+
+// integerType returns the basic integer type underlying t or nil
+func integerType(t types.Type) *types.Basic {
+	if t == nil {
+		return nil;
+	}
+	if basic, ok := t.Underlying().(*types.Basic); ok && basic.Info()&types.IsInteger != 0 {
+		return basic;
+	}
+	return nil;
+}
+
+// intToStrCheck reports string(i) for integers, which yields a rune not digits
+func intToStrCheck(f *File, call *ast.CallExpr) {
+	target, arg := conversion(f, call);
+	if target == nil {
+		return;
+	}
+	if basic, ok := target.Underlying().(*types.Basic); !ok || basic.Info()&types.IsString == 0 {
+		return;
+	}
+	basic := integerType(f.pkg.info.TypeOf(arg));
+	if basic == nil {
+		return;
+	}
+	// a rune or byte is meant to be converted to a character, as is a rune
+	// literal like string('a'), but untyped constants like string(80) are reported
+	if basic.Kind() == types.UntypedRune {
+		return;
+	}
+	switch basic.Name() {
+	case "rune", "byte":
+		return;
+	}
+	f.Reportf(call.Pos(), "integer possibly converted improperly, use strconv: %s", f.ASTString(call));
+}
+
+// isSignedToUnsigned reports whether call converts a signed
+// non-constant integer to an unsigned type
+func isSignedToUnsigned(f *File, x ast.Expr) bool {
+	call, ok := ast.Unparen(x).(*ast.CallExpr);
+	if !ok {
+		return false;
+	}
+	target, arg := conversion(f, call);
+	to, from := integerType(target), integerType(f.pkg.info.TypeOf(arg))
+	if to == nil || from == nil || isConstant(f, arg) {
+		return false;
+	}
+	return to.Info()&types.IsUnsigned != 0 && from.Info()&types.IsUnsigned == 0;
+}
+
+// makeLenCheck reports make([]T, uint(n)) where a negative n becomes huge
+func makeLenCheck(f *File, call *ast.CallExpr) {
+	if id, ok := call.Fun.(*ast.Ident); !ok || id.Name != "make" {
+		return;
+	}
+	if _, ok := f.pkg.info.Uses[call.Fun.(*ast.Ident)].(*types.Builtin); !ok {
+		return;
+	}
+	for _, arg := range call.Args[1:] {
+		if isSignedToUnsigned(f, arg) {
+			f.Reportf(call.Pos(), "signed to unsigned conversion used as make size: %s", f.ASTString(arg));
+		}
+	}
+}
+
+// indexCheck reports slice indices converted from signed to unsigned integers
+func indexCheck(f *File, body *ast.BlockStmt) {
+	report := func(x ast.Expr) {
+		if x != nil && isSignedToUnsigned(f, x) {
+			f.Reportf(x.Pos(), "signed to unsigned conversion used as index: %s", f.ASTString(x));
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.IndexExpr:
+			report(x.Index);
+		case *ast.SliceExpr:
+			report(x.Low);
+			report(x.High);
+			report(x.Max);
+		}
+		return true;
+	})
+}
+
+// parsedBits returns the bit size of the result of a strconv parse call
+// or 0 if call is not one
+func parsedBits(f *File, call *ast.CallExpr) int64 {
+	switch calleeName(f, call) {
+	case "strconv.Atoi":
+		return intSizes.Sizeof(types.Typ[types.Int]) * 8;
+	case "strconv.ParseInt", "strconv.ParseUint":
+		if len(call.Args) != 3 {
+			return 0;
+		}
+		bits := int64(64)
+		if v := f.pkg.info.Types[call.Args[2]].Value; v != nil {
+			if b, ok := constant.Int64Val(v); ok && b > 0 {
+				bits = b;
+			}
+		}
+		return bits;
+	}
+	return 0;
+}
+
+// narrowingCheck reports conversions of strconv parse results to smaller
+// integer types when the result is never compared against a bound
+func narrowingCheck(f *File, body *ast.BlockStmt) {
+	bits := make(map[types.Object]int64)
+	signed := make(map[types.Object]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt);
+		if !ok || len(assign.Rhs) != 1 || len(assign.Lhs) == 0 {
+			return true;
+		}
+		call, ok := assign.Rhs[0].(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		if b := parsedBits(f, call); b > 0 {
+			if id, ok := assign.Lhs[0].(*ast.Ident); ok {
+				if obj := f.pkg.info.ObjectOf(id); obj != nil {
+					bits[obj] = b;
+					signed[obj] = calleeName(f, call) != "strconv.ParseUint";
+				}
+			}
+		}
+		return true;
+	})
+	if len(bits) == 0 {
+		return;
+	}
+
+	// any relational comparison counts as a bounds check
+	bounded := make(map[types.Object]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if bin, ok := n.(*ast.BinaryExpr); ok {
+			switch bin.Op {
+			case token.LSS, token.GTR, token.LEQ, token.GEQ:
+				for _, x := range []ast.Expr{bin.X, bin.Y} {
+					if id, ok := ast.Unparen(x).(*ast.Ident); ok {
+						bounded[f.pkg.info.ObjectOf(id)] = true;
+					}
+				}
+			}
+		}
+		return true;
+	})
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		target, arg := conversion(f, call);
+		to := integerType(target);
+		id, ok := ast.Unparen(arg).(*ast.Ident);
+		if to == nil || !ok {
+			return true;
+		}
+		obj := f.pkg.info.ObjectOf(id);
+		b, parsed := bits[obj];
+		if !parsed || bounded[obj] {
+			return true;
+		}
+		size := intSizes.Sizeof(to) * 8
+		if size < b {
+			f.Reportf(call.Pos(), "parsed %d bit integer truncated to %d bits without bounds check: %s", b, size, f.ASTString(call));
+		} else if signed[obj] && to.Info()&types.IsUnsigned != 0 {
+			f.Reportf(call.Pos(), "parsed signed integer converted to unsigned without bounds check: %s", f.ASTString(call));
+		}
+		return true;
+	})
+}
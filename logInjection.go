@@ -0,0 +1,208 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"strings"
+)
+
+func init() {
+	register("logInjection",
+		"this tests for request data logged without removing newlines and secrets written to logs",
+		logCheck,
+		funcDecl,
+		funcLit)
+}
+
+// logging functions mapped to the index of their first logged argument
+func logCalls() map[string]int {
+	calls := make(map[string]int)
+	for _, name := range []string{"Print", "Printf", "Println", "Fatal", "Fatalf", "Fatalln", "Panic", "Panicf", "Panicln"} {
+		calls["log."+name] = 0;
+		calls["(*log.Logger)."+name] = 0;
+	}
+	for _, name := range []string{"Debug", "Info", "Warn", "Error"} {
+		calls["log/slog."+name] = 0;
+		calls["(*log/slog.Logger)."+name] = 0;
+		calls["log/slog."+name+"Context"] = 1;
+		calls["(*log/slog.Logger)."+name+"Context"] = 1;
+	}
+	calls["log/slog.Log"] = 2;
+	calls["(*log/slog.Logger).Log"] = 2;
+	// only when writing to os.Stderr or os.Stdout
+	calls["fmt.Fprintf"] = 1;
+	calls["fmt.Fprint"] = 1;
+	calls["fmt.Fprintln"] = 1;
+
+	return calls;
+}
+
+// names that suggest a value should not be logged, on top of -secrets.names
+var sensitiveLogNames = []string{"authorization", "cookie", "session", "privatekey", "private_key"}
+
+// isSensitiveName reports whether a name suggests a secret
+func isSensitiveName(name string) bool {
+	if isSecretName(name) {
+		return true;
+	}
+	name = strings.ToLower(name);
+	for _, s := range sensitiveLogNames {
+		if strings.Contains(name, s) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// isRequestDump is a taint source for httputil request and response dumps
+func isRequestDump(f *File, x ast.Expr) bool {
+	call, ok := x.(*ast.CallExpr);
+	if !ok {
+		return false;
+	}
+	switch calleeName(f, call) {
+	case "net/http/httputil.DumpRequest", "net/http/httputil.DumpRequestOut", "net/http/httputil.DumpResponse":
+		return true;
+	}
+	return false;
+}
+
+// isStdStream reports whether x is os.Stderr or os.Stdout
+func isStdStream(f *File, x ast.Expr) bool {
+	sel, ok := x.(*ast.SelectorExpr);
+	if !ok || importedPath(f, sel) != "os" {
+		return false;
+	}
+	return sel.Sel.Name == "Stderr" || sel.Sel.Name == "Stdout";
+}
+
+// quotedArgs returns which arguments after a constant format are printed with %q
+func quotedArgs(f *File, format ast.Expr) map[int]bool {
+	quoted := make(map[int]bool)
+	v := f.pkg.info.Types[format].Value
+	if v == nil || v.Kind() != constant.String {
+		return quoted;
+	}
+	s := constant.StringVal(v)
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		// skip flags, width and precision
+		j := i + 1
+		for j < len(s) && strings.IndexByte("+-# 0123456789.*[]", s[j]) >= 0 {
+			j++;
+		}
+		if j >= len(s) {
+			break;
+		}
+		if s[j] != '%' {
+			quoted[n] = s[j] == 'q';
+			n++;
+		}
+		i = j;
+	}
+	return quoted;
+}
+
+func logCheck(f *File, node ast.Node) {
+	var body *ast.BlockStmt
+	switch fun := node.(type) {
+	case *ast.FuncDecl:
+		body = fun.Body;
+	case *ast.FuncLit:
+		// http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {...})
+		body = handlerBody(f, fun);
+	}
+	if body == nil {
+		return;
+	}
+	calls := logCalls();
+	input := newTaint(f, body, isRequestInput, "replace", "quote");
+	dumps := newTaint(f, body, isRequestDump);
+
+	inspectOwn(f, body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		name := calleeName(f, call);
+		first, ok := calls[name];
+		if !ok || first >= len(call.Args) {
+			return true;
+		}
+		if strings.HasPrefix(name, "fmt.") && !isStdStream(f, call.Args[0]) {
+			return true;
+		}
+		args := call.Args[first:]
+		slog := strings.Contains(name, "slog")
+		quoted := make(map[int]bool)
+		if strings.HasSuffix(name, "f") && len(args) > 0 {
+			// arguments are numbered after the format
+			for i, q := range quotedArgs(f, args[0]) {
+				quoted[i+1] = q;
+			}
+		}
+
+		for i, arg := range args {
+			if dumps.isTainted(arg) {
+				f.Reportf(arg.Pos(), "request dump logged, it includes credentials and cookies: %s", f.ASTString(arg));
+				continue
+			}
+			if reason := sensitiveArg(f, arg); reason != "" {
+				f.Reportf(arg.Pos(), "%s logged: %s", reason, f.ASTString(arg));
+				continue
+			}
+			// slog key value pairs, "password", pw
+			if slog && i > 1 {
+				if key, ok := stringValue(f, args[i-1]); ok && isSensitiveName(key) && !isConstant(f, arg) {
+					f.Reportf(arg.Pos(), "value for %s logged: %s", key, f.ASTString(arg));
+					continue
+				}
+			}
+			// slog quotes attribute values itself, only the message can inject
+			if (!slog || i == 0) && !quoted[i] && input.isUnchecked(arg) && !isConstant(f, arg) {
+				f.Reportf(arg.Pos(), "request data logged without removing newlines: %s", f.ASTString(arg));
+			}
+		}
+		return true;
+	})
+}
+
+// sensitiveArg describes why a logged value is sensitive or returns ""
+func sensitiveArg(f *File, x ast.Expr) string {
+	x = ast.Unparen(x);
+	switch e := x.(type) {
+	case *ast.Ident:
+		if isSensitiveName(e.Name) && !isConstant(f, e) {
+			return "sensitive variable " + e.Name;
+		}
+	case *ast.SelectorExpr:
+		if e.Sel.Name == "Header" && isNamedType(f.pkg.info.TypeOf(e.X), "net/http", "Request") {
+			return "all request headers";
+		}
+		if isSensitiveName(e.Sel.Name) && !isConstant(f, e) {
+			return "sensitive field " + e.Sel.Name;
+		}
+	case *ast.CallExpr:
+		switch calleeName(f, e) {
+		case "(net/http.Header).Get", "(net/http.Header).Values":
+			if len(e.Args) == 1 {
+				if key, ok := stringValue(f, e.Args[0]); ok && isSensitiveName(key) {
+					return key + " header";
+				}
+			}
+		case "log/slog.String", "log/slog.Any":
+			if len(e.Args) == 2 {
+				if key, ok := stringValue(f, e.Args[0]); ok && isSensitiveName(key) && !isConstant(f, e.Args[1]) {
+					return "attribute " + key;
+				}
+			}
+		}
+	}
+	return "";
+}
@@ -0,0 +1,175 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var goVersion = flag.String("goversion", "", "go version of the analysed code, i.e. 1.21, when it has no go.mod")
+
+func init() {
+	register("loopCapture",
+		"this tests for closures that capture loop variables before Go 1.22",
+		loopCaptureCheck,
+		forStmt,
+		rangeStmt)
+}
+
+// go.mod versions found by directory
+var moduleVersions = make(map[string]string)
+
+// moduleGoVersion returns the go directive of the go.mod governing a file,
+// or -goversion if there is none
+func moduleGoVersion(name string) string {
+	dir, err := filepath.Abs(filepath.Dir(name));
+	if err != nil {
+		return *goVersion;
+	}
+	var visited []string
+	version := *goVersion
+	for {
+		if v, ok := moduleVersions[dir]; ok {
+			version = v;
+			break;
+		}
+		visited = append(visited, dir);
+		if v, ok := readGoDirective(filepath.Join(dir, "go.mod")); ok {
+			version = v;
+			break;
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break;
+		}
+		dir = parent;
+	}
+	for _, d := range visited {
+		moduleVersions[d] = version;
+	}
+	return version;
+}
+
+// readGoDirective reads the go line of a go.mod file
+func readGoDirective(path string) (string, bool) {
+	file, err := os.Open(path);
+	if err != nil {
+		return "", false;
+	}
+	defer file.Close();
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text());
+		if len(fields) == 2 && fields[0] == "go" {
+			return fields[1], true;
+		}
+	}
+	// a go.mod without a go line means go 1.16
+	return "1.16", true;
+}
+
+// perIterationLoops reports whether a go version, like 1.22 or go1.21.3,
+// gives each loop iteration its own variables
+func perIterationLoops(version string) bool {
+	parts := strings.Split(strings.TrimPrefix(version, "go"), ".");
+	if len(parts) < 2 {
+		// unknown, assume the old semantics
+		return false;
+	}
+	major, err1 := strconv.Atoi(parts[0]);
+	minor, err2 := strconv.Atoi(strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }));
+	if err1 != nil || err2 != nil {
+		return false;
+	}
+	return major > 1 || minor >= 22;
+}
+
+// loopVars returns the variables declared by a for or range statement
+func loopVars(f *File, node ast.Node) []types.Object {
+	var idents []ast.Expr
+	switch loop := node.(type) {
+	case *ast.RangeStmt:
+		if loop.Tok == token.DEFINE {
+			idents = append(idents, loop.Key, loop.Value);
+		}
+	case *ast.ForStmt:
+		if init, ok := loop.Init.(*ast.AssignStmt); ok && init.Tok == token.DEFINE {
+			idents = append(idents, init.Lhs...);
+		}
+	}
+	var vars []types.Object
+	for _, x := range idents {
+		if id, ok := x.(*ast.Ident); ok && id.Name != "_" {
+			if obj := f.pkg.info.Defs[id]; obj != nil {
+				vars = append(vars, obj);
+			}
+		}
+	}
+	return vars;
+}
+
+func loopCaptureCheck(f *File, node ast.Node) {
+	var body *ast.BlockStmt
+	switch loop := node.(type) {
+	case *ast.RangeStmt:
+		body = loop.Body;
+	case *ast.ForStmt:
+		body = loop.Body;
+	default:
+		return;
+	}
+	vars := loopVars(f, node);
+	if len(vars) == 0 || perIterationLoops(moduleGoVersion(f.name)) {
+		return;
+	}
+	report := func(lit *ast.FuncLit, how string) {
+		for _, obj := range vars {
+			if capturesVar(f, lit, obj) {
+				f.Reportf(lit.Pos(), "closure %s captures loop variable %s, which is shared by all iterations before Go 1.22", how, obj.Name());
+			}
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.GoStmt:
+			if lit, ok := x.Call.Fun.(*ast.FuncLit); ok {
+				report(lit, "started with go");
+			}
+		case *ast.DeferStmt:
+			if lit, ok := x.Call.Fun.(*ast.FuncLit); ok {
+				report(lit, "deferred");
+			}
+		case *ast.CallExpr:
+			// callbacks = append(callbacks, func() {...})
+			if id, ok := x.Fun.(*ast.Ident); ok && id.Name == "append" {
+				for _, arg := range x.Args[1:] {
+					if lit, ok := arg.(*ast.FuncLit); ok {
+						report(lit, "appended");
+					}
+				}
+			}
+		}
+		return true;
+	})
+}
+
+// capturesVar reports whether a function literal refers to obj
+func capturesVar(f *File, lit *ast.FuncLit, obj types.Object) bool {
+	found := false
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && f.pkg.info.Uses[id] == obj {
+			found = true;
+		}
+		return !found;
+	})
+	return found;
+}
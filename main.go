@@ -14,6 +14,7 @@ import (
 	"strings"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
 var stdImporter types.Importer
@@ -73,6 +74,9 @@ type File struct {
 
 	// a map of all registered checkers to run for each node
 	checkers map[ast.Node][]func(*File, ast.Node);
+
+	// findings already reported by some checker, see ReportOncef
+	reported map[string]bool
 }
 
 // Reportf reports issues to a log for each file for later printing
@@ -81,6 +85,21 @@ func (f *File) Reportf(pos token.Pos, format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "\t* %v %s \n", f.loc(pos), fmt.Sprintf(format, args...));
 }
 
+// ReportOncef is Reportf for findings that more than one checker makes,
+// such as an unchecked type assertion, only the first report of a kind
+// of finding on a line is printed. Checkers run in name order.
+func (f *File) ReportOncef(kind string, pos token.Pos, format string, args ...interface{}) {
+	key := kind + " " + f.loc(pos)
+	if f.reported[key] {
+		return;
+	}
+	if f.reported == nil {
+		f.reported = make(map[string]bool);
+	}
+	f.reported[key] = true;
+	f.Reportf(pos, format, args...);
+}
+
 // loc (line of code) returns a formatted string of file and a file position
 func (f *File) loc(pos token.Pos) string {
 	if pos == token.NoPos {
@@ -158,6 +177,7 @@ type Package struct {
 	types 	map[ast.Expr]types.TypeAndValue;
 	typePkg	*types.Package
 	info	*types.Info
+	files	[]*ast.File // every parsed file, for checks that need the whole package
 }
 
 func (pkg *Package) check(fs *token.FileSet, astFiles []*ast.File) error {
@@ -180,12 +200,16 @@ func (pkg *Package) check(fs *token.FileSet, astFiles []*ast.File) error {
 
 	info := types.Info{
 		Types: pkg.types,
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
 	}
 
 	// Type-Check the package.
 	typePkg, err := conf.Check(pkg.path, fs, astFiles, &info);
 	pkg.typePkg = typePkg
 	pkg.info = &info;
+	pkg.files = astFiles;
 	return err;
 	
 }
@@ -280,11 +304,17 @@ func checkPackage(names []string) {
 
 	chk := make(map[ast.Node][]func(*File, ast.Node));
 	for typ, set := range checkers {
-		for name, fn := range set {
+		// a fixed order so ReportOncef picks the same checker every run
+		var names []string
+		for name := range set {
+			names = append(names, name);
+		}
+		sort.Strings(names);
+		for _, name := range names {
 			// check to see if named function will be run and reported
 			_, ok := report[name];
 			if ok {
-				chk[typ] = append(chk[typ], fn);
+				chk[typ] = append(chk[typ], set[name]);
 			}
 		}
 	}
@@ -362,6 +392,79 @@ func getFullFuncName(node ast.Node) (string, error) {
 	return "", fmt.Errorf("type conversion of CallExpr failed, no name extracted, %v", node);
 }
 
+// getCallee returns the function or method called by a CallExpr
+// using type info, so import aliases and method receivers are resolved.
+// It returns nil for conversions, builtins and calls of func values.
+func getCallee(f *File, call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun;
+	case *ast.SelectorExpr:
+		id = fun.Sel;
+	default:
+		return nil;
+	}
+	if fn, ok := f.pkg.info.Uses[id].(*types.Func); ok {
+		return fn;
+	}
+	return nil;
+}
+
+// calleeName returns the fully qualified name of a called function
+// i.e. net/http.Get or (*net/http.Client).Do
+// it returns an empty string when the callee cannot be resolved
+func calleeName(f *File, call *ast.CallExpr) string {
+	if fn := getCallee(f, call); fn != nil {
+		return fn.FullName();
+	}
+	return "";
+}
+
+// importedPath returns the import path of the package a qualified
+// identifier like cors.Options refers to, even when the import failed
+func importedPath(f *File, x ast.Expr) string {
+	sel, ok := x.(*ast.SelectorExpr);
+	if !ok {
+		return "";
+	}
+	id, ok := sel.X.(*ast.Ident);
+	if !ok {
+		return "";
+	}
+	if pkg, ok := f.pkg.info.Uses[id].(*types.PkgName); ok {
+		return pkg.Imported().Path();
+	}
+	// a failed import of gopkg.in/yaml.v3 leaves yaml undefined
+	// so fall back to matching the file's imports by name
+	for _, imp := range f.file.Imports {
+		path := strings.Trim(imp.Path.Value, "\"`");
+		if imp.Name == nil && id.Name == importName(path) {
+			return path;
+		}
+		if imp.Name != nil && imp.Name.Name == id.Name {
+			return path;
+		}
+	}
+	return "";
+}
+
+// importName guesses the package name of an import path by convention,
+// jwt for github.com/golang-jwt/jwt/v5, github.com/dgrijalva/jwt-go and yaml for gopkg.in/yaml.v3
+func importName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	// a major version suffix is not the package name
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2];
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i];
+	}
+	name = strings.TrimPrefix(strings.TrimSuffix(name, "-go"), "go-");
+	return strings.Replace(name, "-", "", -1);
+}
+
 func main() {
 	var runOnDirs, runOnFiles bool;
 	flag.Parse();
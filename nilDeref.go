@@ -0,0 +1,421 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+func init() {
+	register("nilDeref",
+		"this tests for values used when the error returned with them was not nil or not checked",
+		nilDerefCheck,
+		funcDecl,
+		funcLit)
+}
+
+// why a tracked value may be nil
+const (
+	nilUnchecked uint8 = 1 << iota	// the error or the value itself was not checked
+	nilFailed			// the error was not nil
+)
+
+// nilDefs are the assignments that may have last defined a value or error,
+// more than one when paths defining it differently join
+type nilDefs map[ast.Node]bool
+
+// overlaps reports whether d and o share an assignment
+func (d nilDefs) overlaps(o nilDefs) bool {
+	for n := range d {
+		if o[n] {
+			return true;
+		}
+	}
+	return false;
+}
+
+// first returns the earliest assignment in d, to explain a report
+func (d nilDefs) first() ast.Node {
+	var first ast.Node
+	for n := range d {
+		if first == nil || n.Pos() < first.Pos() {
+			first = n;
+		}
+	}
+	return first;
+}
+
+// nilState is what is known at one point of a function
+// about values that may be nil
+type nilState struct {
+	bits	map[types.Object]uint8
+	def	map[types.Object]nilDefs
+	errDef	map[types.Object]nilDefs
+}
+
+func newNilState() *nilState {
+	return &nilState{
+		bits:	make(map[types.Object]uint8),
+		def:	make(map[types.Object]nilDefs),
+		errDef:	make(map[types.Object]nilDefs),
+	}
+}
+
+func (s *nilState) copy() *nilState {
+	c := newNilState()
+	for k, v := range s.bits {
+		c.bits[k] = v;
+	}
+	copyDefs := func(dst, src map[types.Object]nilDefs) {
+		for k, defs := range src {
+			dst[k] = make(nilDefs);
+			for n := range defs {
+				dst[k][n] = true;
+			}
+		}
+	}
+	copyDefs(c.def, s.def);
+	copyDefs(c.errDef, s.errDef);
+	return c;
+}
+
+// join merges another path into s and reports whether s changed
+func (s *nilState) join(o *nilState) bool {
+	changed := false
+	for k, v := range o.bits {
+		if s.bits[k] | v != s.bits[k] {
+			s.bits[k] |= v;
+			changed = true;
+		}
+	}
+	joinDefs := func(dst, src map[types.Object]nilDefs) {
+		for k, defs := range src {
+			if dst[k] == nil {
+				dst[k] = make(nilDefs);
+			}
+			for n := range defs {
+				if !dst[k][n] {
+					dst[k][n] = true;
+					changed = true;
+				}
+			}
+		}
+	}
+	joinDefs(s.def, o.def);
+	joinDefs(s.errDef, o.errDef);
+	return changed;
+}
+
+// canBeNil reports whether a value of type t can be nil and panics when used
+func canBeNil(t types.Type) bool {
+	if t == nil {
+		return false;
+	}
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface:
+		return true;
+	}
+	return false;
+}
+
+// isMapLookup reports whether x is m[k] for a map holding pointers or interfaces
+func isMapLookup(f *File, x ast.Expr) bool {
+	index, ok := x.(*ast.IndexExpr);
+	if !ok {
+		return false;
+	}
+	// the type is unknown when an import failed
+	t := f.pkg.info.TypeOf(index.X)
+	if t == nil {
+		return false;
+	}
+	if m, ok := t.Underlying().(*types.Map); ok {
+		return canBeNil(m.Elem());
+	}
+	return false;
+}
+
+// nilAssign updates s for values and errors assigned in lhs = rhs
+func nilAssign(f *File, s *nilState, node ast.Node, lhs, rhs []ast.Expr) {
+	objOf := func(x ast.Expr) types.Object {
+		if id, ok := x.(*ast.Ident); ok && id.Name != "_" {
+			return f.pkg.info.ObjectOf(id);
+		}
+		return nil;
+	}
+	// v, err := f()
+	if len(rhs) == 1 && len(lhs) > 1 {
+		if call, ok := rhs[0].(*ast.CallExpr); ok {
+			if index := returnsError(f, call); index >= 0 && index < len(lhs) {
+				for i, x := range lhs {
+					obj := objOf(x)
+					if obj == nil {
+						continue
+					}
+					if i == index {
+						s.errDef[obj] = nilDefs{node: true};
+					} else if canBeNil(obj.Type()) {
+						s.bits[obj] = nilUnchecked;
+						s.def[obj] = nilDefs{node: true};
+					} else {
+						delete(s.bits, obj);
+					}
+				}
+				return;
+			}
+		}
+	}
+	// v := m[k]
+	if len(rhs) == 1 && len(lhs) == 1 && isMapLookup(f, rhs[0]) {
+		if obj := objOf(lhs[0]); obj != nil {
+			s.bits[obj] = nilUnchecked;
+			s.def[obj] = nilDefs{node: true};
+		}
+		return;
+	}
+	// anything else replaces the value and unpairs the error
+	for _, x := range lhs {
+		if obj := objOf(x); obj != nil {
+			delete(s.bits, obj);
+			if _, ok := s.errDef[obj]; ok {
+				s.errDef[obj] = nilDefs{node: true};
+			}
+		}
+	}
+}
+
+// nilTransfer applies the assignments in node to s
+func nilTransfer(f *File, s *nilState, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.AssignStmt:
+		nilAssign(f, s, n, n.Lhs, n.Rhs);
+	case *ast.DeclStmt:
+		if gen, ok := n.Decl.(*ast.GenDecl); ok {
+			for _, spec := range gen.Specs {
+				if vs, ok := spec.(*ast.ValueSpec); ok {
+					names := make([]ast.Expr, len(vs.Names))
+					for i, name := range vs.Names {
+						names[i] = name;
+					}
+					nilAssign(f, s, n, names, vs.Values);
+				}
+			}
+		}
+	case *ast.RangeStmt:
+		nilAssign(f, s, n, []ast.Expr{n.Key, n.Value}, nil);
+	}
+}
+
+// nilRefine returns the state on the branch of cond that was taken
+func nilRefine(f *File, cond ast.Expr, branch bool, s *nilState) *nilState {
+	switch c := cond.(type) {
+	case *ast.ParenExpr:
+		return nilRefine(f, c.X, branch, s);
+	case *ast.UnaryExpr:
+		if c.Op == token.NOT {
+			return nilRefine(f, c.X, !branch, s);
+		}
+	case *ast.BinaryExpr:
+		switch c.Op {
+		case token.LAND:
+			if branch {
+				return nilRefine(f, c.Y, true, nilRefine(f, c.X, true, s));
+			}
+			out := nilRefine(f, c.X, false, s)
+			out.join(nilRefine(f, c.Y, false, nilRefine(f, c.X, true, s)));
+			return out;
+		case token.LOR:
+			if !branch {
+				return nilRefine(f, c.Y, false, nilRefine(f, c.X, false, s));
+			}
+			out := nilRefine(f, c.X, true, s)
+			out.join(nilRefine(f, c.Y, true, nilRefine(f, c.X, false, s)));
+			return out;
+		case token.EQL, token.NEQ:
+			x := c.X
+			if isNilIdent(f, x) {
+				x = c.Y;
+			} else if !isNilIdent(f, c.Y) {
+				break;
+			}
+			id, ok := x.(*ast.Ident);
+			if !ok {
+				break;
+			}
+			obj := f.pkg.info.ObjectOf(id)
+			// whether x is nil on this branch
+			isNil := (c.Op == token.EQL) == branch
+			out := s.copy()
+			if _, ok := out.bits[obj]; ok && !isNil {
+				delete(out.bits, obj);
+			}
+			// the check covers values from any assignment that may have set the error
+			if defs, ok := out.errDef[obj]; ok {
+				for v := range out.bits {
+					if !out.def[v].overlaps(defs) {
+						continue
+					}
+					if isNil {
+						delete(out.bits, v);
+					} else {
+						out.bits[v] = nilFailed;
+					}
+				}
+			}
+			return out;
+		}
+	}
+	return s.copy();
+}
+
+// isNilIdent reports whether x is the predeclared nil
+func isNilIdent(f *File, x ast.Expr) bool {
+	if id, ok := x.(*ast.Ident); ok {
+		_, isNil := f.pkg.info.ObjectOf(id).(*types.Nil);
+		return isNil;
+	}
+	return false;
+}
+
+func nilDerefCheck(f *File, node ast.Node) {
+	var body *ast.BlockStmt
+	switch fun := node.(type) {
+	case *ast.FuncDecl:
+		body = fun.Body;
+	case *ast.FuncLit:
+		body = fun.Body;
+	}
+	if body == nil {
+		return;
+	}
+	g := newCFG(f, body);
+	in := make([]*nilState, len(g.blocks))
+	in[g.entry.index] = newNilState();
+	work := []*cfgBlock{g.entry}
+	for len(work) > 0 {
+		block := work[len(work)-1]
+		work = work[:len(work)-1];
+		s := in[block.index].copy()
+		for _, n := range block.nodes {
+			nilTransfer(f, s, n);
+		}
+		for _, edge := range block.succs {
+			out := s
+			if edge.cond != nil {
+				out = nilRefine(f, edge.cond, edge.branch, s);
+			}
+			if in[edge.to.index] == nil {
+				in[edge.to.index] = out.copy();
+				work = append(work, edge.to);
+			} else if in[edge.to.index].join(out) {
+				work = append(work, edge.to);
+			}
+		}
+	}
+	reported := make(map[token.Pos]bool)
+	for _, block := range g.blocks {
+		if in[block.index] == nil {
+			// unreachable
+			continue
+		}
+		s := in[block.index].copy()
+		for _, n := range block.nodes {
+			if _, ok := n.(*ast.RangeStmt); !ok {
+				nilUses(f, n, s, reported);
+			}
+			nilTransfer(f, s, n);
+		}
+	}
+}
+
+// nilUses reports dereferences in n of values that may be nil in s
+func nilUses(f *File, n ast.Node, s *nilState, reported map[token.Pos]bool) {
+	// kind names findings other checkers make too, see ReportOncef
+	report := func(kind string, pos token.Pos, format string, args ...interface{}) {
+		if !reported[pos] {
+			reported[pos] = true;
+			f.ReportOncef(kind, pos, format, args...);
+		}
+	}
+	ast.Inspect(n, func(m ast.Node) bool {
+		var x ast.Expr
+		switch e := m.(type) {
+		case *ast.FuncLit:
+			return false;
+		case *ast.BinaryExpr:
+			// the right side only runs on one branch of the left
+			switch e.Op {
+			case token.LAND:
+				nilUses(f, e.X, s, reported);
+				nilUses(f, e.Y, nilRefine(f, e.X, true, s), reported);
+				return false;
+			case token.LOR:
+				nilUses(f, e.X, s, reported);
+				nilUses(f, e.Y, nilRefine(f, e.X, false, s), reported);
+				return false;
+			}
+		case *ast.SelectorExpr:
+			x = e.X;
+		case *ast.StarExpr:
+			x = e.X;
+		}
+		if x == nil {
+			return true;
+		}
+		for {
+			paren, ok := x.(*ast.ParenExpr);
+			if !ok {
+				break;
+			}
+			x = paren.X;
+		}
+		switch v := x.(type) {
+		case *ast.Ident:
+			obj := f.pkg.info.Uses[v]
+			bits := s.bits[obj]
+			// only the first use of each variable, keyed by where it is declared
+			if bits == 0 || reported[obj.Pos()] {
+				break;
+			}
+			reported[obj.Pos()] = true;
+			report("unchecked error", v.Pos(), "%s", nilMessage(f, v.Name, bits, s.def[obj].first()));
+		case *ast.IndexExpr:
+			if isMapLookup(f, v) {
+				report("map lookup", v.Pos(), "dereference of map lookup %s which is nil for missing keys, use the comma-ok form", f.ASTString(v));
+			}
+		case *ast.TypeAssertExpr:
+			if v.Type != nil {
+				report("type assertion", v.Pos(), "dereference of type assertion %s without the comma-ok form panics when the type does not match", f.ASTString(v));
+			}
+		}
+		return true;
+	})
+}
+
+// nilMessage explains why name may be nil from how it was defined
+func nilMessage(f *File, name string, bits uint8, def ast.Node) string {
+	var rhs ast.Expr
+	switch d := def.(type) {
+	case *ast.AssignStmt:
+		rhs = d.Rhs[0];
+	case *ast.DeclStmt:
+		if gen, ok := d.Decl.(*ast.GenDecl); ok && len(gen.Specs) == 1 {
+			if vs, ok := gen.Specs[0].(*ast.ValueSpec); ok && len(vs.Values) > 0 {
+				rhs = vs.Values[0];
+			}
+		}
+	}
+	switch r := rhs.(type) {
+	case *ast.CallExpr:
+		if bits & nilFailed != 0 {
+			return name + " used where the error from " + f.ASTString(r.Fun) + " is not nil, it may be nil";
+		}
+		return name + " used before the error from " + f.ASTString(r.Fun) + " is checked, it may be nil";
+	case *ast.IndexExpr:
+		return name + " from map lookup " + f.ASTString(r) + " used without a nil check, it is nil for missing keys";
+	}
+	return name + " may be nil here, it is not checked on every path";
+}
@@ -0,0 +1,318 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+var panicEntries = flag.String("panic.entries", "", "comma separated functions, like Parse or (*pkg.Server).Serve, to treat as entry points besides HTTP handlers, or exported for every exported function")
+
+func init() {
+	register("panic",
+		"this tests for panics reachable from HTTP handlers and entry points and goroutines without recover",
+		panicCheck,
+		funcDecl)
+}
+
+// functions reachable from an entry point in each package, computed once per package,
+// mapped to a description of the entry point they are reached from
+var panicReach = make(map[*Package]map[types.Object]string)
+
+// isEntryPoint describes fun if it is given with -panic.entries, or returns ""
+func isEntryPoint(fun *ast.FuncDecl, obj types.Object) string {
+	for _, name := range strings.Split(*panicEntries, ",") {
+		name = strings.TrimSpace(name);
+		switch {
+		case name == "":
+		case name == "exported" && obj.Exported():
+			return "exported " + fun.Name.Name;
+		case name == fun.Name.Name, name == obj.(*types.Func).FullName():
+			return "entry point " + name;
+		}
+	}
+	return "";
+}
+
+// packageFuncs maps the functions and methods declared in a package to their declarations
+func packageFuncs(f *File) map[types.Object]*ast.FuncDecl {
+	decls := make(map[types.Object]*ast.FuncDecl)
+	for _, file := range f.pkg.files {
+		for _, decl := range file.Decls {
+			if fun, ok := decl.(*ast.FuncDecl); ok && fun.Body != nil {
+				if obj := f.pkg.info.Defs[fun.Name]; obj != nil {
+					decls[obj] = fun;
+				}
+			}
+		}
+	}
+	return decls;
+}
+
+// findPanicReach follows calls and function values from HTTP handlers
+// and configured entry points to every function of the package they reach
+func findPanicReach(f *File) map[types.Object]string {
+	if reach, ok := panicReach[f.pkg]; ok {
+		return reach;
+	}
+	reach := make(map[types.Object]string)
+	panicReach[f.pkg] = reach;
+	decls := packageFuncs(f);
+
+	var queue []types.Object
+	// follow the functions used inside node
+	follow := func(node ast.Node, entry string) {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				obj := f.pkg.info.Uses[id]
+				if _, ok := decls[obj]; ok && reach[obj] == "" {
+					reach[obj] = entry;
+					queue = append(queue, obj);
+				}
+			}
+			return true;
+		})
+	}
+	// in source order so the entry named for a function does not change between runs
+	for _, file := range f.pkg.files {
+		for _, decl := range file.Decls {
+			fun, ok := decl.(*ast.FuncDecl);
+			if !ok || fun.Body == nil {
+				continue
+			}
+			obj := f.pkg.info.Defs[fun.Name]
+			if obj == nil || reach[obj] != "" {
+				continue
+			}
+			entry := isEntryPoint(fun, obj)
+			if handlerBody(f, fun) != nil {
+				entry = "HTTP handler " + fun.Name.Name;
+			}
+			if entry != "" {
+				reach[obj] = entry;
+				queue = append(queue, obj);
+			}
+		}
+	}
+	// handlers written as function literals are entries too
+	for _, file := range f.pkg.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.FuncLit); ok && handlerBody(f, lit) != nil {
+				follow(lit.Body, "HTTP handler literal");
+			}
+			return true;
+		})
+	}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:];
+		follow(decls[obj].Body, reach[obj]);
+	}
+	return reach;
+}
+
+func panicCheck(f *File, node ast.Node) {
+	fun, ok := node.(*ast.FuncDecl);
+	if !ok || fun.Body == nil {
+		return;
+	}
+	reach := findPanicReach(f);
+	if entry := reach[f.pkg.info.Defs[fun.Name]]; entry != "" {
+		panicSites(f, fun.Body, entry);
+		return;
+	}
+	// only the handler literals of an unreachable function
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.FuncLit); ok && handlerBody(f, lit) != nil {
+			panicSites(f, lit.Body, "HTTP handler literal");
+			return false;
+		}
+		return true;
+	})
+}
+
+// panicSites reports the ways body can panic, reached from entry
+func panicSites(f *File, body *ast.BlockStmt, entry string) {
+	t := newTaint(f, body, isRequestInput);
+	decls := packageFuncs(f);
+	// assertions in v, ok := x.(T) form
+	commaOk := make(map[ast.Expr]bool)
+	// variables bounds checked with a comparison or a len call
+	compared := make(map[types.Object]bool)
+	lenChecked := make(map[types.Object]bool)
+	// maps declared without a value and never assigned one
+	nilMaps := make(map[types.Object]bool)
+	assigned := make(map[types.Object]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if len(x.Lhs) == 2 && len(x.Rhs) == 1 {
+				commaOk[x.Rhs[0]] = true;
+			}
+			for _, lhs := range x.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					assigned[f.pkg.info.ObjectOf(id)] = true;
+				}
+			}
+		case *ast.ValueSpec:
+			if len(x.Names) == 2 && len(x.Values) == 1 {
+				commaOk[x.Values[0]] = true;
+			}
+			if len(x.Values) == 0 {
+				for _, name := range x.Names {
+					obj := f.pkg.info.Defs[name]
+					if _, ok := obj.Type().Underlying().(*types.Map); ok {
+						nilMaps[obj] = true;
+					}
+				}
+			}
+		case *ast.BinaryExpr:
+			switch x.Op {
+			case token.LSS, token.LEQ, token.GTR, token.GEQ:
+				for _, side := range []ast.Expr{x.X, x.Y} {
+					ast.Inspect(side, func(m ast.Node) bool {
+						if id, ok := m.(*ast.Ident); ok {
+							compared[f.pkg.info.ObjectOf(id)] = true;
+						}
+						return true;
+					})
+				}
+			}
+		case *ast.CallExpr:
+			if id, ok := x.Fun.(*ast.Ident); ok && id.Name == "len" && len(x.Args) == 1 {
+				if root := rootIdent(x.Args[0]); root != nil {
+					lenChecked[f.pkg.info.ObjectOf(root)] = true;
+				}
+			}
+		}
+		return true;
+	})
+	for obj := range assigned {
+		delete(nilMaps, obj);
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CallExpr:
+			if id, ok := x.Fun.(*ast.Ident); ok && id.Name == "panic" {
+				if _, builtin := f.pkg.info.Uses[id].(*types.Builtin); builtin {
+					f.Reportf(x.Pos(), "panic reachable from %s: %s", entry, f.ASTString(x));
+				}
+			}
+		case *ast.TypeAssertExpr:
+			if x.Type != nil && !commaOk[x] {
+				f.ReportOncef("type assertion", x.Pos(), "type assertion without comma-ok reachable from %s panics on a mismatch: %s", entry, f.ASTString(x));
+			}
+		case *ast.IndexExpr:
+			if !isSequence(f.pkg.info.TypeOf(x.X)) {
+				break;
+			}
+			if !isConstant(f, x.Index) && t.isTainted(x.Index) && !mentionsAny(f, x.Index, compared) {
+				f.Reportf(x.Pos(), "request controlled index reachable from %s may be out of range: %s", entry, f.ASTString(x));
+			} else if isConstant(f, x.Index) && t.isTainted(x.X) && !mentionsAny(f, x.X, lenChecked) {
+				f.Reportf(x.Pos(), "index into request controlled data reachable from %s without a length check: %s", entry, f.ASTString(x));
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range x.Lhs {
+				if index, ok := lhs.(*ast.IndexExpr); ok {
+					if id, ok := index.X.(*ast.Ident); ok && nilMaps[f.pkg.info.ObjectOf(id)] {
+						f.Reportf(lhs.Pos(), "write to nil map reachable from %s: %s", entry, f.ASTString(lhs));
+					}
+				}
+			}
+		case *ast.GoStmt:
+			if recovers(f, x.Call, decls) {
+				break;
+			}
+			started := "func literal"
+			if _, ok := x.Call.Fun.(*ast.FuncLit); !ok {
+				started = f.ASTString(x.Call);
+			}
+			f.Reportf(x.Pos(), "goroutine started from %s without recover, a panic in it crashes the whole server: %s", entry, started);
+		}
+		return true;
+	})
+}
+
+// isSequence reports whether indexing t can go out of range
+func isSequence(t types.Type) bool {
+	if t == nil {
+		return false;
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem();
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Slice, *types.Array:
+		return true;
+	case *types.Basic:
+		return u.Info()&types.IsString != 0;
+	}
+	return false;
+}
+
+// mentionsAny reports whether x refers to any of objs
+func mentionsAny(f *File, x ast.Expr, objs map[types.Object]bool) bool {
+	found := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && objs[f.pkg.info.ObjectOf(id)] {
+			found = true;
+		}
+		return !found;
+	})
+	return found;
+}
+
+// recovers reports whether the function started by a go statement
+// defers a call to recover, directly or through a function of the package
+func recovers(f *File, call *ast.CallExpr, decls map[types.Object]*ast.FuncDecl) bool {
+	var body *ast.BlockStmt
+	if lit, ok := call.Fun.(*ast.FuncLit); ok {
+		body = lit.Body;
+	} else if fn := getCallee(f, call); fn != nil {
+		decl, ok := decls[fn];
+		if !ok {
+			// declared elsewhere, nothing to say
+			return true;
+		}
+		body = decl.Body;
+	} else {
+		return true;
+	}
+	for _, stmt := range body.List {
+		d, ok := stmt.(*ast.DeferStmt);
+		if !ok {
+			continue
+		}
+		// defer func() { if r := recover(); ... }() or defer handlePanic()
+		var deferred ast.Node = d.Call.Fun
+		if fn := getCallee(f, d.Call); fn != nil {
+			if decl, ok := decls[fn]; ok {
+				deferred = decl.Body;
+			}
+		}
+		if callsRecover(f, deferred) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// callsRecover reports whether node contains a call to the recover builtin
+func callsRecover(f *File, node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "recover" {
+				_, found = f.pkg.info.Uses[id].(*types.Builtin);
+			}
+		}
+		return !found;
+	})
+	return found;
+}
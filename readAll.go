@@ -5,43 +5,162 @@ package main
 
 import (
 	"go/ast"
-	"strings"
+	"go/token"
+	"go/types"
 )
 
 func init() {
 	register("readAll",
-		"this tests checks of use of ioutil.ReadAll needs to be audited",
+		"this tests if unbounded network input or decompressed data is read into memory",
 		readAllCheck,
-		callExpr)
+		funcDecl,
+		funcLit)
 }
 
-// this checks for the bad function
-// but maybe abstract this and create a function name extractor
-// then throw all the bad functions together or check for them
-// using it
-func readAllCheck(f *File, node ast.Node) {
-	// the names of the called functions
-	// new function getFullFuncName does all this work.
-	// todo: replace
-	var names []string
-	var callName string	
-	if call, ok := node.(*ast.CallExpr); ok {
-		if fun, ok := call.Fun.(*ast.SelectorExpr); ok {
-			// SelectorExpr has two fields
-			// X and Sel
-			// X (through reflection) was found to be an Ident
-			// Sel has field Name
-			// Ident's have a field Name also.
-			if id, ok := (fun.X).(*ast.Ident); ok {
-				names = append(names, id.Name);
-				names = append(names, fun.Sel.Name);	
-				callName = strings.Join(names, "/")
-				if(callName == "ioutil/ReadAll") {
-					callStr := f.ASTString(call);
-					f.Reportf(node.Pos(), "audit use of ioutil.ReadAll %s", callStr);
-				} 	
+// calls that read everything from the reader at the given argument index
+func unboundedReads() map[string]int {
+	calls := make(map[string]int)
+	calls["io.ReadAll"]			= 0
+	calls["io/ioutil.ReadAll"]		= 0
+	calls["encoding/json.NewDecoder"]	= 0
+	calls["(*bytes.Buffer).ReadFrom"]	= 0
+
+	return calls;
+}
+
+// decompressors whose output can be far larger than their input
+func decompressors() map[string]bool {
+	calls := make(map[string]bool)
+	calls["compress/gzip.NewReader"]	= true
+	calls["compress/zlib.NewReader"]	= true
+	calls["compress/flate.NewReader"]	= true
+	calls["compress/bzip2.NewReader"]	= true
+	calls["compress/lzw.NewReader"]		= true
+
+	return calls;
+}
+
+// isNetConn reports whether t is net.Conn or one of the concrete connections
+func isNetConn(t types.Type) bool {
+	for _, name := range []string{"Conn", "TCPConn", "UDPConn", "UnixConn", "IPConn"} {
+		if isNamedType(t, "net", name) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// isUnboundedReader is a taint source for readers of network input,
+// request and response bodies, connections and decompressors
+func isUnboundedReader(f *File, x ast.Expr) bool {
+	switch e := x.(type) {
+	case *ast.SelectorExpr:
+		if e.Sel.Name != "Body" {
+			return false;
+		}
+		t := f.pkg.info.TypeOf(e.X);
+		return isNamedType(t, "net/http", "Request") || isNamedType(t, "net/http", "Response");
+	case *ast.CallExpr:
+		return decompressors()[calleeName(f, e)];
+	case *ast.Ident:
+		return isNetConn(f.pkg.info.TypeOf(e));
+	}
+	return false;
+}
+
+// limitedBodies returns the requests whose body is replaced by
+// r.Body = http.MaxBytesReader(w, r.Body, n) in a function body
+// with the position of the wrap, reads before it are still unbounded
+func limitedBodies(f *File, body ast.Node) map[types.Object]token.Pos {
+	limited := make(map[types.Object]token.Pos)
+	ast.Inspect(body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt);
+		if !ok || len(assign.Lhs) != len(assign.Rhs) {
+			return true;
+		}
+		for i, lhs := range assign.Lhs {
+			sel, ok := lhs.(*ast.SelectorExpr);
+			if !ok || sel.Sel.Name != "Body" {
+				continue
+			}
+			call, ok := assign.Rhs[i].(*ast.CallExpr);
+			if !ok {
+				continue
+			}
+			switch calleeName(f, call) {
+			case "net/http.MaxBytesReader", "io.LimitReader":
+				if id := rootIdent(sel.X); id != nil {
+					// the first wrap, later ones are visited after it
+					if _, ok := limited[f.pkg.info.ObjectOf(id)]; !ok {
+						limited[f.pkg.info.ObjectOf(id)] = assign.Pos();
+					}
+				}
 			}
 		}
+		return true;
+	})
+	return limited;
+}
+
+// newReaderTaint tracks unbounded readers in a function body, a reader
+// wrapped in http.MaxBytesReader or io.LimitReader is no longer tracked
+func newReaderTaint(f *File, body ast.Node) *taint {
+	limited := limitedBodies(f, body);
+	source := func(f *File, x ast.Expr) bool {
+		if sel, ok := x.(*ast.SelectorExpr); ok {
+			if id := rootIdent(sel.X); id != nil {
+				if pos, ok := limited[f.pkg.info.ObjectOf(id)]; ok && sel.Pos() > pos {
+					return false;
+				}
+			}
+		}
+		return isUnboundedReader(f, x);
 	}
-	return;
+	return newTaint(f, body, source, "maxbytesreader", "limitreader");
+}
+
+// isMemoryBuffer reports whether t is an in memory writer
+func isMemoryBuffer(t types.Type) bool {
+	return isNamedType(t, "bytes", "Buffer") || isNamedType(t, "strings", "Builder");
+}
+
+// readAllCheck reports reads of a whole unbounded reader into memory
+func readAllCheck(f *File, node ast.Node) {
+	var body *ast.BlockStmt
+	switch fun := node.(type) {
+	case *ast.FuncDecl:
+		body = fun.Body;
+	case *ast.FuncLit:
+		// http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {...})
+		body = handlerBody(f, fun);
+	}
+	if body == nil {
+		return;
+	}
+	calls := unboundedReads();
+	t := newReaderTaint(f, body);
+
+	inspectOwn(f, body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		name := calleeName(f, call);
+		switch name {
+		case "io.Copy", "io.CopyBuffer":
+			// io.Copy(&buf, gz)
+			if len(call.Args) >= 2 && isMemoryBuffer(f.pkg.info.TypeOf(call.Args[0])) && t.isTainted(call.Args[1]) {
+				f.Reportf(call.Pos(), "audit copy of unbounded input into memory: %s", f.ASTString(call));
+			}
+			return true;
+		}
+		index, ok := calls[name];
+		if !ok || index >= len(call.Args) {
+			return true;
+		}
+		if t.isTainted(call.Args[index]) {
+			f.Reportf(call.Pos(), "audit read of unbounded input into memory, use http.MaxBytesReader or io.LimitReader: %s", f.ASTString(call));
+		}
+		return true;
+	})
 }
@@ -0,0 +1,175 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("openRedirect",
+		"this tests if HTTP handlers redirect to locations taken from request input",
+		redirectCheck,
+		funcDecl,
+		funcLit)
+}
+
+// isHandler reports whether a function type has the
+// func(http.ResponseWriter, *http.Request) signature.
+// this also matches http.HandlerFunc and literals converted to it
+func isHandler(t types.Type) bool {
+	if t == nil {
+		return false;
+	}
+	sig, ok := t.Underlying().(*types.Signature);
+	if !ok || sig.Params().Len() != 2 || sig.Results().Len() != 0 {
+		return false;
+	}
+	w, r := sig.Params().At(0).Type(), sig.Params().At(1).Type()
+	if _, ok := r.(*types.Pointer); !ok {
+		return false;
+	}
+	return isNamedType(w, "net/http", "ResponseWriter") && isNamedType(r, "net/http", "Request");
+}
+
+// handlerBody returns the body of a handler function declaration or literal
+// and nil for anything else
+func handlerBody(f *File, node ast.Node) *ast.BlockStmt {
+	switch fn := node.(type) {
+	case *ast.FuncDecl:
+		if obj := f.pkg.info.Defs[fn.Name]; obj != nil && isHandler(obj.Type()) {
+			return fn.Body;
+		}
+	case *ast.FuncLit:
+		if isHandler(f.pkg.info.TypeOf(fn)) {
+			return fn.Body;
+		}
+	}
+	return nil;
+}
+
+// isRedirectSource is a taint source for query parameters,
+// form values and the Referer header of an incoming request
+func isRedirectSource(f *File, x ast.Expr) bool {
+	fromRequest := func(x ast.Expr) bool {
+		id := rootIdent(x);
+		return id != nil && isNamedType(f.pkg.info.TypeOf(id), "net/http", "Request");
+	}
+	switch e := x.(type) {
+	case *ast.CallExpr:
+		sel, ok := e.Fun.(*ast.SelectorExpr);
+		if !ok || !fromRequest(sel.X) {
+			return false;
+		}
+		switch calleeName(f, e) {
+		case "(*net/http.Request).FormValue",
+			"(*net/http.Request).PostFormValue",
+			"(*net/http.Request).Referer",
+			"(*net/url.URL).Query":
+			return true;
+		case "(net/http.Header).Get", "(net/http.Header).Values":
+			if len(e.Args) == 1 {
+				if v := f.pkg.info.Types[e.Args[0]].Value; v != nil && v.Kind() == constant.String {
+					return strings.EqualFold(constant.StringVal(v), "Referer");
+				}
+			}
+		}
+	case *ast.SelectorExpr:
+		switch e.Sel.Name {
+		case "Form", "PostForm", "RawQuery":
+			return fromRequest(e.X);
+		}
+	}
+	return false;
+}
+
+// redirectCheck reports http.Redirect calls and Location headers
+// in handlers whose target comes from request input and is not validated
+func redirectCheck(f *File, node ast.Node) {
+	body := handlerBody(f, node);
+	if body == nil {
+		return;
+	}
+	// HasPrefix and IsAbs alone let //evil.com through, see localRedirectChecks
+	t := newTaint(f, body, isRedirectSource, "relative", "local");
+	localRedirectChecks(f, t, body);
+	formatString := "audit redirect to location from request input without validation: %s"
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			// nested handlers are checked on their own
+			return !isHandler(f.pkg.info.TypeOf(x));
+		case *ast.CallExpr:
+			switch calleeName(f, x) {
+			case "net/http.Redirect":
+				if len(x.Args) == 4 && t.isUnchecked(x.Args[2]) {
+					f.Reportf(x.Pos(), formatString, f.ASTString(x));
+				}
+			case "(net/http.Header).Set", "(net/http.Header).Add":
+				if len(x.Args) == 2 && isLocationKey(f, x.Args[0]) && t.isUnchecked(x.Args[1]) {
+					f.Reportf(x.Pos(), formatString, f.ASTString(x));
+				}
+			}
+		case *ast.AssignStmt:
+			// w.Header()["Location"] = []string{target}
+			for i, lhs := range x.Lhs {
+				index, ok := lhs.(*ast.IndexExpr);
+				if !ok || i >= len(x.Rhs) || !isLocationKey(f, index.Index) {
+					continue
+				}
+				if isNamedType(f.pkg.info.TypeOf(index.X), "net/http", "Header") && t.isUnchecked(x.Rhs[i]) {
+					f.Reportf(x.Pos(), formatString, f.ASTString(lhs));
+				}
+			}
+		}
+		return true;
+	})
+}
+
+// localRedirectChecks marks targets as checked when they are restricted to local paths:
+// a HasPrefix "/" check together with checks for "//" and "/\\"
+func localRedirectChecks(f *File, t *taint, body ast.Node) {
+	slash := make(map[types.Object]bool)
+	double := make(map[types.Object]bool)
+	backslash := make(map[types.Object]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CallExpr:
+			if calleeName(f, x) != "strings.HasPrefix" || len(x.Args) != 2 {
+				break;
+			}
+			prefix, ok := stringValue(f, x.Args[1]);
+			obj := t.objectOf(rootIdent(x.Args[0]));
+			if !ok || obj == nil {
+				break;
+			}
+			switch prefix {
+			case "/":
+				slash[obj] = true;
+			case "//":
+				double[obj] = true;
+			case "/\\":
+				backslash[obj] = true;
+			}
+		}
+		return true;
+	})
+	for obj := range slash {
+		if double[obj] && backslash[obj] {
+			t.checked[obj] = true;
+		}
+	}
+}
+
+// isLocationKey reports whether x is the constant header name Location
+func isLocationKey(f *File, x ast.Expr) bool {
+	if v := f.pkg.info.Types[x].Value; v != nil && v.Kind() == constant.String {
+		return strings.EqualFold(constant.StringVal(v), "Location");
+	}
+	return false;
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"regexp/syntax"
+	"strings"
+)
+
+func init() {
+	register("regex",
+		"this tests for regular expressions compiled from input, compiled repeatedly or with costly repetition",
+		regexCheck,
+		funcDecl,
+		callExpr)
+}
+
+// regexp functions that compile their first argument on every call
+func regexCompilers() map[string]bool {
+	calls := make(map[string]bool)
+	calls["regexp.Compile"]			= true
+	calls["regexp.MustCompile"]		= true
+	calls["regexp.CompilePOSIX"]		= true
+	calls["regexp.MustCompilePOSIX"]	= true
+	calls["regexp.Match"]			= true
+	calls["regexp.MatchString"]		= true
+	calls["regexp.MatchReader"]		= true
+
+	return calls;
+}
+
+// most copies a constant pattern may expand to through counted repetition,
+// Go rejects patterns past 1000 but programs well below that are already large
+const maxRegexRepeat = 100
+
+func regexCheck(f *File, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.CallExpr:
+		regexPatternCheck(f, n);
+	case *ast.FuncDecl:
+		if n.Body == nil {
+			return;
+		}
+		t := newTaint(f, n.Body, isRequestInput, "quotemeta");
+		regexPlacementCheck(f, t, n.Body, false, handlerBody(f, n) != nil);
+	}
+}
+
+// regexPlacementCheck walks a function body reporting patterns from request input
+// and compilation inside loops or handlers, which is repeated for every iteration or request
+func regexPlacementCheck(f *File, t *taint, body ast.Node, inLoop, inHandler bool) {
+	compilers := regexCompilers();
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ForStmt:
+			regexPlacementCheck(f, t, x.Body, true, inHandler);
+			return false;
+		case *ast.RangeStmt:
+			regexPlacementCheck(f, t, x.Body, true, inHandler);
+			return false;
+		case *ast.FuncLit:
+			if x != body {
+				regexPlacementCheck(f, t, x.Body, false, isHandler(f.pkg.info.TypeOf(x)));
+				return false;
+			}
+		case *ast.CallExpr:
+			if !compilers[calleeName(f, x)] || len(x.Args) == 0 {
+				break;
+			}
+			pattern := x.Args[0]
+			if t.isUnchecked(pattern) {
+				f.Reportf(x.Pos(), "regular expression compiled from request input, use regexp.QuoteMeta: %s", f.ASTString(x));
+				break;
+			}
+			if !isConstant(f, pattern) {
+				break;
+			}
+			switch {
+			case inLoop:
+				f.Reportf(x.Pos(), "constant regular expression compiled inside a loop, compile it once at package scope: %s", f.ASTString(x));
+			case inHandler:
+				f.Reportf(x.Pos(), "constant regular expression compiled on every request, compile it once at package scope: %s", f.ASTString(x));
+			}
+		}
+		return true;
+	})
+}
+
+// regexPatternCheck parses constant patterns and reports nested
+// repetition that makes the compiled program very large
+func regexPatternCheck(f *File, call *ast.CallExpr) {
+	if !regexCompilers()[calleeName(f, call)] || len(call.Args) == 0 {
+		return;
+	}
+	v := f.pkg.info.Types[call.Args[0]].Value
+	if v == nil || v.Kind() != constant.String {
+		return;
+	}
+	flags := syntax.Perl
+	if strings.HasSuffix(calleeName(f, call), "POSIX") {
+		// egrep syntax, without \d or (?i)
+		flags = syntax.POSIX;
+	}
+	re, err := syntax.Parse(constant.StringVal(v), flags);
+	if err != nil {
+		f.Reportf(call.Pos(), "invalid regular expression: %s", err);
+		return;
+	}
+	size, depth := regexCost(re);
+	if size > maxRegexRepeat {
+		f.Reportf(call.Pos(), "regular expression repetition expands to about %d copies: %s", size, f.ASTString(call.Args[0]));
+	} else if depth > 1 {
+		f.Reportf(call.Pos(), "regular expression has nested repetition: %s", f.ASTString(call.Args[0]));
+	}
+}
+
+// regexCost returns how many times the most repeated part of a pattern
+// is copied by counted repetition, and the deepest nesting of repetition
+func regexCost(re *syntax.Regexp) (size, depth int) {
+	size = 1
+	for _, sub := range re.Sub {
+		s, d := regexCost(sub);
+		if s > size {
+			size = s;
+		}
+		if d > depth {
+			depth = d;
+		}
+	}
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus:
+		depth++;
+	case syntax.OpRepeat:
+		n := re.Max
+		if n < 0 {
+			n = re.Min;
+		}
+		if n > 1 {
+			size *= n;
+		}
+		if re.Max < 0 || re.Max > 1 {
+			depth++;
+		}
+	}
+	return size, depth;
+}
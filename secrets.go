@@ -0,0 +1,234 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	secretEntropy = flag.Float64("secrets.entropy", 3.0, "minimum Shannon entropy in bits per character for a literal key or value of a secret name to be reported")
+	secretNames = flag.String("secrets.names", "password,passwd,pwd,secret,token,apikey,api_key,privatekey,private_key,credential", "comma separated name fragments that suggest a secret")
+)
+
+func init() {
+	register("secrets",
+		"this tests for hardcoded passwords, keys and tokens",
+		secretsCheck,
+		fileNode)
+}
+
+// secretFormat is a well known format of key or token
+type secretFormat struct {
+	kind	string
+	re	*regexp.Regexp
+}
+
+// well known formats of keys and tokens, in the order they are tried
+func secretFormats() []secretFormat {
+	return []secretFormat{
+		{"private key",		regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY( BLOCK)?-----`)},
+		{"AWS access key",	regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{"Google API key",	regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+		{"GitHub token",	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+		{"Slack token",		regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9\-]{10,}`)},
+		{"JWT",			regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)},
+	}
+}
+
+// passwords in sql.Open data source names, user:pass@ or password=pass
+// the password may not contain / so that scheme://user@host is not read as one
+var dsnPassword = regexp.MustCompile(`(?i)^(?:[a-z0-9+]+://)?[^:/@\s]+:([^@\s/]+)@|password=([^\s;&]+)`)
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	if s == "" {
+		return 0;
+	}
+	counts := make(map[rune]float64)
+	for _, r := range s {
+		counts[r]++;
+	}
+	n := float64(len([]rune(s)))
+	var h float64
+	for _, c := range counts {
+		p := c / n
+		h -= p * math.Log2(p);
+	}
+	return h;
+}
+
+// redact keeps only enough of a secret to find it again
+func redact(s string) string {
+	keep := len(s) / 8
+	if keep > 4 {
+		keep = 4;
+	}
+	return fmt.Sprintf("%q (%d chars redacted)", s[:keep]+"...", len(s)-keep);
+}
+
+// isSecretName checks an identifier against the configured name fragments
+func isSecretName(name string) bool {
+	name = strings.ToLower(name);
+	for _, fragment := range strings.Split(*secretNames, ",") {
+		fragment = strings.ToLower(strings.TrimSpace(fragment));
+		if fragment != "" && strings.Contains(name, fragment) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// stringValue returns the constant string value of x, looking through
+// []byte("...") conversions
+func stringValue(f *File, x ast.Expr) (string, bool) {
+	if call, ok := x.(*ast.CallExpr); ok && len(call.Args) == 1 && f.pkg.info.Types[call.Fun].IsType() {
+		x = call.Args[0];
+	}
+	if v := f.pkg.info.Types[x].Value; v != nil && v.Kind() == constant.String {
+		return constant.StringVal(v), true;
+	}
+	return "", false;
+}
+
+// nameOf returns the name of an assignment target or field key
+func nameOf(x ast.Expr) string {
+	switch e := x.(type) {
+	case *ast.Ident:
+		return e.Name;
+	case *ast.SelectorExpr:
+		return e.Sel.Name;
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			return strings.Trim(e.Value, "\"`");
+		}
+	}
+	return "";
+}
+
+// isSecretValue reports whether a string assigned to a secret looking name
+// could be the secret, rather than a field name, URL or path
+func isSecretValue(name, s string) bool {
+	if entropy(s) < *secretEntropy {
+		return false;
+	}
+	// const passwordField = "password"
+	lower := strings.ToLower(s)
+	for _, word := range nameWords(name) {
+		if len(word) > 2 && strings.Contains(lower, word) {
+			return false;
+		}
+	}
+	// tokenURL = "https://auth.example.com/token"
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.Host != "" {
+		return false;
+	}
+	for _, prefix := range []string{"/", "./", "../", "~/"} {
+		if strings.HasPrefix(s, prefix) {
+			return false;
+		}
+	}
+	return true;
+}
+
+func secretsCheck(f *File, node ast.Node) {
+	file, ok := node.(*ast.File);
+	if !ok {
+		return;
+	}
+	formats := secretFormats();
+	// a literal is reported once even if several rules match
+	seen := make(map[token.Pos]bool)
+	report := func(x ast.Expr, format string, args ...interface{}) {
+		if seen[x.Pos()] {
+			return;
+		}
+		seen[x.Pos()] = true;
+		f.Reportf(x.Pos(), format, args...);
+	}
+	named := func(name string, value ast.Expr) {
+		if name == "" || value == nil || !isSecretName(name) {
+			return;
+		}
+		if s, ok := stringValue(f, value); ok && isSecretValue(name, s) {
+			report(value, "hardcoded secret assigned to %s: %s", name, redact(s));
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ValueSpec:
+			for i, name := range x.Names {
+				if i < len(x.Values) {
+					named(name.Name, x.Values[i]);
+				}
+			}
+		case *ast.AssignStmt:
+			if len(x.Lhs) == len(x.Rhs) {
+				for i, lhs := range x.Lhs {
+					named(nameOf(lhs), x.Rhs[i]);
+				}
+			}
+		case *ast.KeyValueExpr:
+			named(nameOf(x.Key), x.Value);
+		case *ast.CallExpr:
+			secretSinkCheck(f, x, report);
+		case *ast.BasicLit:
+			if x.Kind != token.STRING {
+				break;
+			}
+			s, ok := stringValue(f, x);
+			if !ok {
+				break;
+			}
+			for _, format := range formats {
+				if m := format.re.FindString(s); m != "" {
+					report(x, "hardcoded %s: %s", format.kind, redact(m));
+					break;
+				}
+			}
+		}
+		return true;
+	})
+}
+
+// secretSinkCheck reports constant keys passed to hmac.New or JWT signing
+// and passwords in sql.Open data source names
+func secretSinkCheck(f *File, call *ast.CallExpr, report func(ast.Expr, string, ...interface{})) {
+	name := calleeName(f, call);
+	var key ast.Expr
+	switch {
+	case name == "crypto/hmac.New" && len(call.Args) == 2:
+		key = call.Args[1];
+	case strings.Contains(name, "jwt") && strings.HasSuffix(name, ").SignedString") && len(call.Args) == 1:
+		key = call.Args[0];
+	case name == "database/sql.Open" && len(call.Args) == 2:
+		dsn, ok := stringValue(f, call.Args[1]);
+		if !ok {
+			return;
+		}
+		if m := dsnPassword.FindStringSubmatch(dsn); m != nil {
+			password := m[1] + m[2]
+			report(call.Args[1], "hardcoded password in sql.Open data source name: %s", redact(password));
+		}
+		return;
+	default:
+		return;
+	}
+	s, ok := stringValue(f, key);
+	if !ok {
+		return;
+	}
+	if entropy(s) >= *secretEntropy {
+		report(key, "hardcoded key passed to %s: %s", name, redact(s));
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+func init() {
+	register("ssrf",
+		"this tests if outgoing requests are made to URLs or hosts taken from request input",
+		ssrfCheck,
+		funcDecl,
+		funcLit,
+		compositeLit,
+		assignStmt)
+}
+
+// outgoingCalls maps functions making outbound connections
+// to the index of the argument holding the URL, request or address
+func outgoingCalls() map[string]int {
+	calls := make(map[string]int)
+	calls["net/http.Get"]				= 0
+	calls["net/http.Head"]				= 0
+	calls["net/http.Post"]				= 0
+	calls["net/http.PostForm"]			= 0
+	calls["net/http.NewRequest"]			= 1
+	calls["net/http.NewRequestWithContext"]		= 2
+	calls["(*net/http.Client).Get"]			= 0
+	calls["(*net/http.Client).Head"]		= 0
+	calls["(*net/http.Client).Post"]		= 0
+	calls["(*net/http.Client).PostForm"]		= 0
+	calls["(*net/http.Client).Do"]			= 0
+	calls["net.Dial"]				= 1
+	calls["net.DialTimeout"]			= 1
+	calls["(*net.Dialer).Dial"]			= 1
+	calls["(*net.Dialer).DialContext"]		= 2
+	calls["net/http/httputil.NewSingleHostReverseProxy"]	= 0
+
+	return calls;
+}
+
+func ssrfCheck(f *File, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		if n.Body != nil {
+			ssrfCheckBody(f, n.Body);
+		}
+	case *ast.FuncLit:
+		// http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {...})
+		if body := handlerBody(f, n); body != nil {
+			ssrfCheckBody(f, body);
+		}
+	case *ast.CompositeLit:
+		// &httputil.ReverseProxy{Director: func(req *http.Request) {...}}
+		if !isNamedType(f.pkg.info.TypeOf(n), "net/http/httputil", "ReverseProxy") {
+			return;
+		}
+		for _, elt := range n.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if key, ok := kv.Key.(*ast.Ident); ok && key.Name == "Director" {
+					directorCheck(f, kv.Value);
+				}
+			}
+		}
+	case *ast.AssignStmt:
+		// proxy.Director = func(req *http.Request) {...}
+		for i, lhs := range n.Lhs {
+			sel, ok := lhs.(*ast.SelectorExpr);
+			if !ok || sel.Sel.Name != "Director" || i >= len(n.Rhs) {
+				continue
+			}
+			if isNamedType(f.pkg.info.TypeOf(sel.X), "net/http/httputil", "ReverseProxy") {
+				directorCheck(f, n.Rhs[i]);
+			}
+		}
+	}
+}
+
+// ssrfCheckBody reports outgoing calls in a function body
+// whose destination comes from request input and is never validated
+func ssrfCheckBody(f *File, body *ast.BlockStmt) {
+	calls := outgoingCalls();
+	t := newTaint(f, body, isRequestInput);
+	// requests already reported when built are not reported again when sent
+	reported := make(map[types.Object]bool)
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		var call *ast.CallExpr
+		var lhs []ast.Expr
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			// nested handlers are checked on their own
+			return !isHandler(f.pkg.info.TypeOf(x));
+		case *ast.AssignStmt:
+			if len(x.Rhs) != 1 {
+				return true;
+			}
+			call, _ = x.Rhs[0].(*ast.CallExpr);
+			lhs = x.Lhs;
+		case *ast.CallExpr:
+			call = x;
+		}
+		if call == nil {
+			return true;
+		}
+		name := calleeName(f, call);
+		index, ok := calls[name];
+		if !ok || index >= len(call.Args) {
+			return true;
+		}
+		arg := call.Args[index];
+		if id := rootIdent(arg); id != nil && reported[t.objectOf(id)] {
+			return true;
+		}
+		if t.isUnchecked(arg) {
+			if lhs != nil {
+				if obj := t.objectOf(rootIdent(lhs[0])); obj != nil {
+					reported[obj] = true;
+				}
+			}
+			f.Reportf(call.Pos(), "audit outgoing request to destination from request input without allowlist check: %s", f.ASTString(call));
+			// the assignment and the call are both visited, report once
+			return false;
+		}
+		return true;
+	})
+}
+
+// isRequestHeader is a taint source for the headers of a *http.Request
+func isRequestHeader(f *File, x ast.Expr) bool {
+	if sel, ok := x.(*ast.SelectorExpr); ok && sel.Sel.Name == "Header" {
+		return isNamedType(f.pkg.info.TypeOf(sel.X), "net/http", "Request");
+	}
+	return false;
+}
+
+// directorCheck reports a ReverseProxy Director that sets the target
+// scheme or host of the proxied request from one of its headers
+func directorCheck(f *File, fn ast.Expr) {
+	lit, ok := fn.(*ast.FuncLit);
+	if !ok {
+		return;
+	}
+	t := newTaint(f, lit.Body, isRequestHeader);
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt);
+		if !ok || len(assign.Lhs) != len(assign.Rhs) {
+			return true;
+		}
+		for i, lhs := range assign.Lhs {
+			if !isProxyTarget(f, lhs) {
+				continue
+			}
+			if t.isUnchecked(assign.Rhs[i]) {
+				f.Reportf(assign.Pos(), "audit reverse proxy target rewritten from request header: %s", f.ASTString(lhs));
+			}
+		}
+		return true;
+	})
+}
+
+// isProxyTarget reports whether x is req.Host, req.URL or req.URL.Host/Scheme
+func isProxyTarget(f *File, x ast.Expr) bool {
+	sel, ok := x.(*ast.SelectorExpr);
+	if !ok {
+		return false;
+	}
+	switch sel.Sel.Name {
+	case "Host", "URL":
+		if isNamedType(f.pkg.info.TypeOf(sel.X), "net/http", "Request") {
+			return true;
+		}
+		fallthrough
+	case "Scheme":
+		return isNamedType(f.pkg.info.TypeOf(sel.X), "net/url", "URL");
+	}
+	return false;
+}
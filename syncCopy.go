@@ -0,0 +1,323 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+func init() {
+	register("syncCopy",
+		"this tests for copied mutexes and other sync values and locks that are not released",
+		syncCopyCheck,
+		funcDecl,
+		funcLit)
+}
+
+// syncTypes are the sync types that must not be copied after first use
+func syncTypes() map[string]bool {
+	names := make(map[string]bool)
+	names["Mutex"]		= true
+	names["RWMutex"]	= true
+	names["WaitGroup"]	= true
+	names["Once"]		= true
+	names["Cond"]		= true
+
+	return names;
+}
+
+// unlocks maps locking methods to the method releasing them
+func unlocks() map[string]string {
+	calls := make(map[string]string)
+	calls["(*sync.Mutex).Lock"]	= "Unlock"
+	calls["(*sync.RWMutex).Lock"]	= "Unlock"
+	calls["(*sync.RWMutex).RLock"]	= "RUnlock"
+
+	return calls;
+}
+
+// lockPath returns the sync type held by t, directly or in a struct or array field,
+// i.e. sync.Mutex or sync.Mutex in field mu
+func lockPath(t types.Type, names map[string]bool, seen map[types.Type]bool) string {
+	if t == nil || seen[t] {
+		return "";
+	}
+	seen[t] = true;
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj();
+		if obj.Pkg() != nil && obj.Pkg().Path() == "sync" && names[obj.Name()] {
+			return "sync." + obj.Name();
+		}
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if path := lockPath(u.Field(i).Type(), names, seen); path != "" {
+				return path + " in field " + u.Field(i).Name();
+			}
+		}
+	case *types.Array:
+		return lockPath(u.Elem(), names, seen);
+	}
+	return "";
+}
+
+// containsLock returns a description of the sync value inside t or ""
+func containsLock(t types.Type) string {
+	return lockPath(t, syncTypes(), make(map[types.Type]bool));
+}
+
+// isCopy reports whether x reads an existing value rather than making a new one
+// composite literals and call results are fresh values and safe to move
+func isCopy(x ast.Expr) bool {
+	switch e := x.(type) {
+	case *ast.Ident:
+		return e.Name != "_" && e.Name != "nil";
+	case *ast.SelectorExpr, *ast.IndexExpr, *ast.StarExpr:
+		return true;
+	case *ast.ParenExpr:
+		return isCopy(e.X);
+	}
+	return false;
+}
+
+func syncCopyCheck(f *File, node ast.Node) {
+	var typ *ast.FuncType
+	var body *ast.BlockStmt
+	switch fun := node.(type) {
+	case *ast.FuncDecl:
+		typ, body = fun.Type, fun.Body;
+		if fun.Recv != nil {
+			fieldsCopyCheck(f, fun.Recv, "receiver");
+		}
+	case *ast.FuncLit:
+		typ, body = fun.Type, fun.Body;
+	default:
+		return;
+	}
+	fieldsCopyCheck(f, typ.Params, "parameter");
+	fieldsCopyCheck(f, typ.Results, "result");
+	if body == nil {
+		return;
+	}
+	valueCopyCheck(f, body);
+	lockCheck(f, body);
+}
+
+// fieldsCopyCheck reports receivers, parameters and results that hold sync values by value
+func fieldsCopyCheck(f *File, fields *ast.FieldList, kind string) {
+	if fields == nil {
+		return;
+	}
+	for _, field := range fields.List {
+		if path := containsLock(f.pkg.info.TypeOf(field.Type)); path != "" {
+			f.Reportf(field.Pos(), "%s passes %s by value: %s", kind, path, f.ASTString(field.Type));
+		}
+	}
+}
+
+// valueCopyCheck reports assignments, arguments, range variables and returns
+// that copy a value holding a sync type
+func valueCopyCheck(f *File, body *ast.BlockStmt) {
+	report := func(x ast.Expr, how string) {
+		if !isCopy(x) {
+			return;
+		}
+		if path := containsLock(f.pkg.info.TypeOf(x)); path != "" {
+			f.Reportf(x.Pos(), "%s copies %s: %s", how, path, f.ASTString(x));
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			// checked on its own
+			return false;
+		case *ast.AssignStmt:
+			for _, rhs := range x.Rhs {
+				report(rhs, "assignment");
+			}
+		case *ast.ValueSpec:
+			for _, v := range x.Values {
+				report(v, "variable declaration");
+			}
+		case *ast.CallExpr:
+			// new(T) and len(x) take types and do not copy
+			if tv, ok := f.pkg.info.Types[x.Fun]; ok && (tv.IsType() || tv.IsBuiltin()) {
+				return true;
+			}
+			for _, arg := range x.Args {
+				report(arg, "call argument");
+			}
+		case *ast.CompositeLit:
+			for _, elt := range x.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					elt = kv.Value;
+				}
+				report(elt, "composite literal");
+			}
+		case *ast.RangeStmt:
+			if x.Value != nil {
+				if path := containsLock(f.pkg.info.TypeOf(x.Value)); path != "" {
+					f.Reportf(x.Value.Pos(), "range value copies %s: %s", path, f.ASTString(x.Value));
+				}
+			}
+		case *ast.ReturnStmt:
+			for _, res := range x.Results {
+				report(res, "return");
+			}
+		}
+		return true;
+	})
+}
+
+// followStmts calls visit for every statement in body with the statements
+// that run after it, innermost block first
+func followStmts(body *ast.BlockStmt, visit func(stmt ast.Stmt, rest [][]ast.Stmt)) {
+	var walk func(stmts []ast.Stmt, outer [][]ast.Stmt)
+	walk = func(stmts []ast.Stmt, outer [][]ast.Stmt) {
+		for i, stmt := range stmts {
+			rest := append([][]ast.Stmt{stmts[i+1:]}, outer...)
+			visit(stmt, rest);
+			for _, inner := range innerBlocks(stmt) {
+				walk(inner, rest);
+			}
+		}
+	}
+	walk(body.List, nil);
+}
+
+// lockCheck reports Lock calls that are not followed by an Unlock
+// of the same value on every path out of the function
+func lockCheck(f *File, body *ast.BlockStmt) {
+	calls := unlocks();
+	followStmts(body, func(stmt ast.Stmt, rest [][]ast.Stmt) {
+		expr, ok := stmt.(*ast.ExprStmt);
+		if !ok {
+			return;
+		}
+		call, ok := expr.X.(*ast.CallExpr);
+		if !ok {
+			return;
+		}
+		unlock, ok := calls[calleeName(f, call)];
+		if !ok {
+			return;
+		}
+		key := f.ASTString(call.Fun.(*ast.SelectorExpr).X)
+		released, leak := releases(f, rest, key + "." + unlock);
+		if leak != nil {
+			f.Reportf(leak.Pos(), "returns while holding %s locked at line %d without %s", key, f.fset.Position(call.Pos()).Line, unlock);
+		} else if !released {
+			f.Reportf(call.Pos(), "%s is never released with %s on some paths", f.ASTString(call), unlock);
+		}
+	})
+}
+
+// innerBlocks returns the statement lists nested directly in stmt
+func innerBlocks(stmt ast.Stmt) [][]ast.Stmt {
+	var blocks [][]ast.Stmt
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		blocks = append(blocks, s.List);
+	case *ast.IfStmt:
+		blocks = append(blocks, s.Body.List);
+		if s.Else != nil {
+			blocks = append(blocks, innerBlocks(s.Else)...);
+		}
+	case *ast.ForStmt:
+		blocks = append(blocks, s.Body.List);
+	case *ast.RangeStmt:
+		blocks = append(blocks, s.Body.List);
+	case *ast.SwitchStmt:
+		blocks = append(blocks, innerBlocks(s.Body)...);
+	case *ast.TypeSwitchStmt:
+		blocks = append(blocks, innerBlocks(s.Body)...);
+	case *ast.SelectStmt:
+		blocks = append(blocks, innerBlocks(s.Body)...);
+	case *ast.CaseClause:
+		blocks = append(blocks, s.Body);
+	case *ast.CommClause:
+		blocks = append(blocks, s.Body);
+	case *ast.LabeledStmt:
+		blocks = append(blocks, innerBlocks(s.Stmt)...);
+	}
+	return blocks;
+}
+
+// isRelease reports whether stmt calls, or defers, the function fun, i.e. mu.Unlock
+func isRelease(f *File, stmt ast.Stmt, fun string) bool {
+	var call *ast.CallExpr
+	switch s := stmt.(type) {
+	case *ast.ExprStmt:
+		call, _ = s.X.(*ast.CallExpr);
+	case *ast.DeferStmt:
+		call = s.Call;
+		// defer func() { mu.Unlock() }()
+		if lit, ok := call.Fun.(*ast.FuncLit); ok {
+			for _, inner := range lit.Body.List {
+				if isRelease(f, inner, fun) {
+					return true;
+				}
+			}
+			return false;
+		}
+	}
+	return call != nil && f.ASTString(call.Fun) == fun;
+}
+
+// releases follows the statements after a Lock, block by block outwards,
+// and reports whether fun releases it before the function ends
+// or the return statement that leaves it held
+func releases(f *File, blocks [][]ast.Stmt, fun string) (bool, ast.Node) {
+	for _, stmts := range blocks {
+		released, leak := releasesIn(f, stmts, fun);
+		if released || leak != nil {
+			return released, leak;
+		}
+	}
+	return false, nil;
+}
+
+// releasesIn is releases for a single statement list
+func releasesIn(f *File, stmts []ast.Stmt, fun string) (bool, ast.Node) {
+	for _, stmt := range stmts {
+		if isRelease(f, stmt, fun) {
+			return true, nil;
+		}
+		switch s := stmt.(type) {
+		case *ast.ReturnStmt:
+			return false, s;
+		case *ast.IfStmt:
+			released, leak := releasesIn(f, s.Body.List, fun);
+			if leak != nil {
+				return false, leak;
+			}
+			if s.Else == nil {
+				continue
+			}
+			elseReleased, leak := releasesIn(f, []ast.Stmt{s.Else}, fun);
+			if leak != nil {
+				return false, leak;
+			}
+			if released && elseReleased {
+				return true, nil;
+			}
+		case *ast.BlockStmt:
+			released, leak := releasesIn(f, s.List, fun);
+			if released || leak != nil {
+				return released, leak;
+			}
+		default:
+			// an Unlock inside a loop or case may not run,
+			// but a return in one leaves the lock held
+			for _, inner := range innerBlocks(stmt) {
+				if _, leak := releasesIn(f, inner, fun); leak != nil {
+					return false, leak;
+				}
+			}
+		}
+	}
+	return false, nil;
+}
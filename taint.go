@@ -0,0 +1,389 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// names of functions that are assumed to validate or sanitize their input
+// a call to one of these in a condition marks the arguments as checked
+var sanitizerNames = []string{
+	"allow",
+	"whitelist",
+	"valid",
+	"permit",
+	"trust",
+	"safe",
+	"sanitiz",
+	"verify",
+}
+
+// taint tracks values derived from a source within a single function body.
+// it is flow insensitive: a value is tainted if it is assigned
+// from a tainted expression anywhere in the body.
+type taint struct {
+	f	*File
+	// source reports whether an expression is itself untrusted input
+	source	func(*File, ast.Expr) bool
+	// sanitizers are lower case name fragments of validating functions
+	sanitizers []string
+	objs	map[types.Object]bool
+	checked	map[types.Object]bool
+}
+
+// newTaint builds the tainted and checked sets for a function body
+func newTaint(f *File, body ast.Node, source func(*File, ast.Expr) bool, sanitizers ...string) *taint {
+	t := &taint{
+		f:	f,
+		source:	source,
+		sanitizers: append(sanitizers, sanitizerNames...),
+		objs:	make(map[types.Object]bool),
+		checked: make(map[types.Object]bool),
+	}
+	if body == nil {
+		return t;
+	}
+	// keep going until nothing new is tainted
+	// so that assignment order inside loops does not matter
+	for t.propagate(body) {
+	}
+	t.findChecks(body);
+	return t;
+}
+
+// objectOf returns the object an identifier defines or refers to
+func (t *taint) objectOf(id *ast.Ident) types.Object {
+	if id == nil || id.Name == "_" {
+		return nil;
+	}
+	return t.f.pkg.info.ObjectOf(id);
+}
+
+// mark taints the variable at the root of an assignment target
+// and reports if it was not already tainted
+func (t *taint) mark(x ast.Expr) bool {
+	obj := t.objectOf(rootIdent(x));
+	if obj == nil || t.objs[obj] {
+		return false;
+	}
+	t.objs[obj] = true;
+	return true;
+}
+
+// propagate does one pass over body and returns true if anything new was tainted
+func (t *taint) propagate(body ast.Node) bool {
+	changed := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.AssignStmt:
+			if len(stmt.Lhs) == len(stmt.Rhs) {
+				for i, rhs := range stmt.Rhs {
+					if t.isTainted(rhs) && t.mark(stmt.Lhs[i]) {
+						changed = true;
+					}
+				}
+				break;
+			}
+			// a, b := f(x) taints every result
+			for _, rhs := range stmt.Rhs {
+				if t.isTainted(rhs) {
+					for _, lhs := range stmt.Lhs {
+						if t.mark(lhs) {
+							changed = true;
+						}
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for _, rhs := range stmt.Values {
+				if !t.isTainted(rhs) {
+					continue
+				}
+				for _, name := range stmt.Names {
+					if t.mark(name) {
+						changed = true;
+					}
+				}
+			}
+		case *ast.CallExpr:
+			// json.Unmarshal(body, &v) and dec.Decode(&v) taint v
+			tainted := false
+			if sel, ok := stmt.Fun.(*ast.SelectorExpr); ok && t.isTainted(sel.X) {
+				tainted = true;
+			}
+			for _, arg := range stmt.Args {
+				if _, ok := arg.(*ast.UnaryExpr); !ok && t.isTainted(arg) {
+					tainted = true;
+				}
+			}
+			if !tainted {
+				break;
+			}
+			for _, arg := range stmt.Args {
+				if u, ok := arg.(*ast.UnaryExpr); ok && u.Op == token.AND && t.mark(u.X) {
+					changed = true;
+				}
+			}
+		case *ast.RangeStmt:
+			if t.isTainted(stmt.X) {
+				for _, x := range []ast.Expr{stmt.Key, stmt.Value} {
+					if x != nil && t.mark(x) {
+						changed = true;
+					}
+				}
+			}
+		}
+		return true;
+	})
+	return changed;
+}
+
+// isSanitizer checks a called function name against the sanitizer list
+func (t *taint) isSanitizer(call *ast.CallExpr) bool {
+	name := strings.ToLower(getFuncName(call));
+	if name == "" {
+		return false;
+	}
+	for _, s := range t.sanitizers {
+		if strings.Contains(name, s) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// isTainted reports whether x refers to a source or a tainted variable
+func (t *taint) isTainted(x ast.Expr) bool {
+	if x == nil {
+		return false;
+	}
+	found := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		if found {
+			return false;
+		}
+		switch e := n.(type) {
+		case *ast.FuncLit:
+			// closures are analysed on their own
+			return false;
+		case *ast.CallExpr:
+			if t.isSanitizer(e) {
+				return false;
+			}
+			// a request's context carries no input
+			if calleeName(t.f, e) == "(*net/http.Request).Context" {
+				return false;
+			}
+		case *ast.IndexExpr:
+			// items[i] picks one of a fixed set of values, it is only input if items is
+			if !t.isTainted(e.X) {
+				return false;
+			}
+		case *ast.Ident:
+			if obj := t.objectOf(e); obj != nil && t.objs[obj] {
+				found = true;
+				return false;
+			}
+		}
+		if e, ok := n.(ast.Expr); ok && t.source(t.f, e) {
+			found = true;
+			return false;
+		}
+		return true;
+	})
+	return found;
+}
+
+// isChecked reports whether a tainted variable in x is validated somewhere
+func (t *taint) isChecked(x ast.Expr) bool {
+	checked := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if obj := t.objectOf(id); obj != nil && t.checked[obj] {
+				checked = true;
+			}
+		}
+		return !checked;
+	})
+	return checked;
+}
+
+// isUnchecked is the usual question, tainted and not validated
+func (t *taint) isUnchecked(x ast.Expr) bool {
+	return t.isTainted(x) && !t.isChecked(x);
+}
+
+// findChecks marks tainted variables that are compared against constants,
+// looked up in a map or passed to a validating function in a condition
+func (t *taint) findChecks(body ast.Node) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.IfStmt:
+			if stmt.Init != nil {
+				// if ok := allowed[host]; ok {
+				if assign, ok := stmt.Init.(*ast.AssignStmt); ok {
+					for _, rhs := range assign.Rhs {
+						if t.isValidation(rhs) {
+							t.markChecked(rhs);
+						}
+					}
+				}
+			}
+			if t.isValidation(stmt.Cond) {
+				t.markChecked(stmt.Cond);
+			}
+		case *ast.SwitchStmt:
+			// switch host { case "a.example.com": }
+			if stmt.Tag != nil && t.isTainted(stmt.Tag) {
+				t.markChecked(stmt.Tag);
+			}
+		case *ast.CaseClause:
+			for _, x := range stmt.List {
+				if t.isValidation(x) {
+					t.markChecked(x);
+				}
+			}
+		}
+		return true;
+	})
+}
+
+// isValidation reports whether a condition looks like an allowlist check
+func (t *taint) isValidation(cond ast.Expr) bool {
+	valid := false
+	ast.Inspect(cond, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.CallExpr:
+			if t.isSanitizer(e) {
+				valid = true;
+			}
+		case *ast.IndexExpr:
+			if typ := t.f.pkg.info.TypeOf(e.X); typ != nil {
+				if _, ok := typ.Underlying().(*types.Map); ok {
+					valid = true;
+				}
+			}
+		case *ast.BinaryExpr:
+			if e.Op == token.EQL || e.Op == token.NEQ {
+				// comparing to "" is a presence check, not validation
+				if isNonEmptyString(t.f, e.X) || isNonEmptyString(t.f, e.Y) {
+					valid = true;
+				}
+			}
+		}
+		return !valid;
+	})
+	return valid;
+}
+
+// markChecked marks every tainted variable in x as validated
+func (t *taint) markChecked(x ast.Expr) {
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if obj := t.objectOf(id); obj != nil && t.objs[obj] {
+				t.checked[obj] = true;
+			}
+		}
+		return true;
+	})
+}
+
+// rootIdent returns the variable at the base of a selector, index, star or address expression
+// i.e. req for req.URL.Host
+func rootIdent(x ast.Expr) *ast.Ident {
+	for {
+		switch e := x.(type) {
+		case *ast.Ident:
+			return e;
+		case *ast.SelectorExpr:
+			x = e.X;
+		case *ast.IndexExpr:
+			x = e.X;
+		case *ast.StarExpr:
+			x = e.X;
+		case *ast.ParenExpr:
+			x = e.X;
+		case *ast.UnaryExpr:
+			// &buf
+			if e.Op != token.AND {
+				return nil;
+			}
+			x = e.X;
+		default:
+			return nil;
+		}
+	}
+}
+
+// isConstant reports whether the type checker computed a constant value for x
+func isConstant(f *File, x ast.Expr) bool {
+	if tv, ok := f.pkg.info.Types[x]; ok {
+		return tv.Value != nil;
+	}
+	return false;
+}
+
+// isNonEmptyString reports whether x is a constant string other than ""
+func isNonEmptyString(f *File, x ast.Expr) bool {
+	if tv, ok := f.pkg.info.Types[x]; ok && tv.Value != nil {
+		return tv.Value.Kind() == constant.String && constant.StringVal(tv.Value) != "";
+	}
+	return false;
+}
+
+// isNamedType reports whether t, or what it points to, is the named type pkg.name
+func isNamedType(t types.Type, pkg, name string) bool {
+	if t == nil {
+		return false;
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem();
+	}
+	named, ok := t.(*types.Named);
+	if !ok {
+		return false;
+	}
+	obj := named.Obj();
+	return obj.Pkg() != nil && obj.Pkg().Path() == pkg && obj.Name() == name;
+}
+
+// request parameters of the functions in each file
+var requestParams = make(map[*File]map[types.Object]bool)
+
+// isRequestParam reports whether id refers to a *http.Request
+// received as a function parameter rather than one built locally
+func isRequestParam(f *File, id *ast.Ident) bool {
+	params, ok := requestParams[f];
+	if !ok {
+		params = make(map[types.Object]bool);
+		ast.Inspect(f.file, func(n ast.Node) bool {
+			if fun, ok := n.(*ast.FuncType); ok {
+				for _, field := range fun.Params.List {
+					for _, name := range field.Names {
+						if obj := f.pkg.info.Defs[name]; obj != nil {
+							params[obj] = true;
+						}
+					}
+				}
+			}
+			return true;
+		})
+		requestParams[f] = params;
+	}
+	return params[f.pkg.info.ObjectOf(id)];
+}
+
+// isRequestInput is a taint source for anything read from an incoming *http.Request
+func isRequestInput(f *File, x ast.Expr) bool {
+	id, ok := x.(*ast.Ident);
+	if !ok || !isNamedType(f.pkg.info.TypeOf(x), "net/http", "Request") {
+		return false;
+	}
+	return isRequestParam(f, id);
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("tempFile",
+		"this tests for predictable temporary file names and temporary files that are never removed",
+		tempFileCheck,
+		funcDecl,
+		funcLit)
+}
+
+// calls creating temporary files or directories with random names
+func tempCreators() map[string]bool {
+	calls := make(map[string]bool)
+	calls["io/ioutil.TempFile"]	= true
+	calls["io/ioutil.TempDir"]	= true
+	calls["os.CreateTemp"]		= true
+	calls["os.MkdirTemp"]		= true
+
+	return calls;
+}
+
+// calls that give up ownership of a temporary path, so it need not be removed here
+func tempReleasers() map[string]bool {
+	calls := make(map[string]bool)
+	calls["os.Remove"]	= true
+	calls["os.RemoveAll"]	= true
+	calls["os.Rename"]	= true
+
+	return calls;
+}
+
+// shared directories anyone can create files in
+var sharedDirs = []string{"/tmp/", "/var/tmp/", "/dev/shm/"}
+
+// tempPath reports whether a path is in a shared temporary directory
+// and whether it is predictable, built only from constants and os.TempDir()
+func tempPath(f *File, x ast.Expr) (shared, predictable bool) {
+	if v := f.pkg.info.Types[x].Value; v != nil && v.Kind() == constant.String {
+		for _, dir := range sharedDirs {
+			if strings.HasPrefix(constant.StringVal(v), dir) {
+				return true, true;
+			}
+		}
+		return false, true;
+	}
+	switch e := x.(type) {
+	case *ast.ParenExpr:
+		return tempPath(f, e.X);
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD {
+			s1, p1 := tempPath(f, e.X);
+			_, p2 := tempPath(f, e.Y);
+			// only the start of a path decides where it is
+			return s1, s1 && p1 && p2;
+		}
+	case *ast.CallExpr:
+		switch calleeName(f, e) {
+		case "os.TempDir":
+			return true, true;
+		case "path/filepath.Join", "path.Join":
+			if len(e.Args) == 0 {
+				return false, false;
+			}
+			shared, predictable = tempPath(f, e.Args[0]);
+			for _, arg := range e.Args[1:] {
+				if _, p := tempPath(f, arg); !p {
+					predictable = false;
+				}
+			}
+			return shared, shared && predictable;
+		}
+	}
+	return false, false;
+}
+
+// hasFlag reports whether an open flag expression names os.O_EXCL, os.O_CREATE and so on
+func hasFlag(x ast.Expr, name string) bool {
+	found := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true;
+		}
+		return !found;
+	})
+	return found;
+}
+
+func tempFileCheck(f *File, node ast.Node) {
+	var body *ast.BlockStmt
+	switch fun := node.(type) {
+	case *ast.FuncDecl:
+		body = fun.Body;
+	case *ast.FuncLit:
+		// http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {...})
+		body = handlerBody(f, fun);
+	}
+	if body == nil {
+		return;
+	}
+	creators := tempCreators();
+	// temporary files created in this function
+	created := make(map[types.Object]*ast.CallExpr)
+	var order []types.Object
+
+	inspectOwn(f, body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if len(x.Rhs) != 1 {
+				break;
+			}
+			if call, ok := x.Rhs[0].(*ast.CallExpr); ok && creators[calleeName(f, call)] {
+				if id, ok := x.Lhs[0].(*ast.Ident); ok && id.Name != "_" {
+					obj := f.pkg.info.ObjectOf(id);
+					created[obj] = call;
+					order = append(order, obj);
+				}
+			}
+		case *ast.CallExpr:
+			switch calleeName(f, x) {
+			case "os.Create", "os.WriteFile", "io/ioutil.WriteFile":
+				if len(x.Args) > 0 {
+					if shared, predictable := tempPath(f, x.Args[0]); shared && predictable {
+						f.Reportf(x.Pos(), "predictable file name in shared temporary directory, use os.CreateTemp: %s", f.ASTString(x));
+					}
+				}
+			case "os.OpenFile":
+				if len(x.Args) != 3 || !hasFlag(x.Args[1], "O_CREATE") || hasFlag(x.Args[1], "O_EXCL") {
+					break;
+				}
+				if shared, _ := tempPath(f, x.Args[0]); shared {
+					f.Reportf(x.Pos(), "file opened in shared temporary directory without O_EXCL: %s", f.ASTString(x));
+				}
+			}
+		}
+		return true;
+	})
+
+	if len(created) == 0 {
+		return;
+	}
+	releasers := tempReleasers();
+	released := make(map[types.Object]bool)
+	inspectOwn(f, body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CallExpr:
+			if !releasers[calleeName(f, x)] {
+				return true;
+			}
+			for _, arg := range x.Args {
+				markReleased(f, arg, released);
+			}
+		case *ast.ReturnStmt:
+			// returned to the caller to clean up, as tmp or tmp.Name()
+			for _, result := range x.Results {
+				markReleased(f, result, released);
+			}
+		}
+		return true;
+	})
+	for _, obj := range order {
+		if call := created[obj]; !released[obj] {
+			f.Reportf(call.Pos(), "temporary file or directory %s is never removed: %s", obj.Name(), f.ASTString(call));
+		}
+	}
+}
+
+// markReleased marks the variable a path is taken from, i.e. tmp for tmp.Name(),
+// filepath.Join(dir, name) is a path inside dir and does not release it
+func markReleased(f *File, x ast.Expr, released map[types.Object]bool) {
+	if call, ok := x.(*ast.CallExpr); ok && getFuncName(call) == "Name" {
+		x = call.Fun;
+	}
+	if id := rootIdent(x); id != nil {
+		if obj := f.pkg.info.ObjectOf(id); obj != nil {
+			released[obj] = true;
+		}
+	}
+}
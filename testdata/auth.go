@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var jwtSecret = "not-so-secret"
+
+var configuredKey = "dev-only"
+
+func init() {
+	flag.StringVar(&configuredKey, "jwt.key", configuredKey, "key to sign tokens with")
+}
+
+func authHandler(w http.ResponseWriter, r *http.Request) {
+	raw := r.Header.Get("Authorization")
+
+	// bad, the key function ignores token.Method and the key is constant
+	// bad, no exp required
+	token, err := jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// bad
+	unverified, _, err := jwt.NewParser().ParseUnverified(raw, jwt.MapClaims{})
+	_ = unverified
+}
+
+func verify(raw string, key []byte) (*jwt.Token, error) {
+	// good
+	return jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key, nil
+	}, jwt.WithExpirationRequired())
+}
+
+func verifyOptions(raw string, key []byte) (*jwt.Token, error) {
+	// good, algorithms restricted by option
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	return parser.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		return key, nil
+	})
+}
+
+// restricted once for the package
+var jwtParser = jwt.NewParser(jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+
+func verifyShared(raw string, key []byte) (*jwt.Token, error) {
+	// good, algorithms restricted by the parser's options
+	return jwtParser.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		return key, nil
+	})
+}
+
+func verifyNone(raw string, key []byte) (*jwt.Token, error) {
+	// bad
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"HS256", "none"}), jwt.WithExpirationRequired(), jwt.WithoutClaimsValidation())
+	return parser.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		// bad
+		return jwt.UnsafeAllowNoneSignatureType, nil
+	})
+}
+
+func sign(user string) (string, error) {
+	claims := jwt.MapClaims{"sub": user, "exp": time.Now().Add(time.Hour).Unix()}
+	// bad
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("hardcoded"))
+}
+
+func signConfigured(user string) (string, error) {
+	claims := jwt.MapClaims{"sub": user, "exp": time.Now().Add(time.Hour).Unix()}
+	// good, the key is set by a flag
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(configuredKey))
+}
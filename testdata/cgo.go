@@ -0,0 +1,23 @@
+package main
+
+// #include <stdlib.h>
+// #include <string.h>
+// void use(char *s, void *p);
+import "C"
+
+import "unsafe"
+
+func cStrings(name string, data []byte) {
+	// good
+	cs := C.CString(name)
+	defer C.free(unsafe.Pointer(cs))
+
+	// bad, never freed
+	leaked := C.CString(name)
+	// bad, Go pointer passed to C
+	C.use(leaked, unsafe.Pointer(&data[0]))
+
+	// bad, never freed
+	C.use(C.CString(name), nil)
+	C.use(cs, nil)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type job struct {
+	// bad
+	ctx	context.Context
+	name	string
+}
+
+func lostCancel(parent context.Context) error {
+	// bad
+	ctx, _ := context.WithTimeout(parent, time.Second)
+	<-ctx.Done()
+
+	// bad, not cancelled on the error path
+	ctx2, cancel2 := context.WithCancel(parent)
+	if ctx2.Err() != nil {
+		return errors.New("cancelled")
+	}
+	cancel2()
+
+	// good
+	ctx3, cancel3 := context.WithDeadline(parent, time.Now().Add(time.Minute))
+	defer cancel3()
+	<-ctx3.Done()
+
+	// bad, a new root context although one was passed in
+	ctx4 := context.Background()
+	_ = ctx4
+	return nil
+}
+
+// good, the caller cancels
+func withTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(parent, time.Second)
+	return ctx, cancel
+}
+
+// good, the watcher cancels
+func watchCancel(parent context.Context, done <-chan struct{}) context.Context {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		<-done
+		cancel()
+	}()
+	return ctx
+}
+
+// good, no context to use
+func root() context.Context {
+	return context.TODO()
+}
+
+func contextHandler(w http.ResponseWriter, r *http.Request) {
+	// bad
+	resp, err := http.Get("https://example.com/")
+	if err == nil {
+		resp.Body.Close()
+	}
+	// bad
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	_ = req
+	// good
+	req2, _ := http.NewRequestWithContext(r.Context(), "GET", "https://example.com/", nil)
+	resp2, err := http.DefaultClient.Do(req2)
+	if err == nil {
+		resp2.Body.Close()
+	}
+}
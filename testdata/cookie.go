@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	// bad, session cookie without Secure or SameSite
+	http.SetCookie(w, &http.Cookie{
+		Name:		"session_id",
+		Value:		"abc",
+		HttpOnly:	true,
+	})
+
+	// good
+	http.SetCookie(w, &http.Cookie{
+		Name:		"session_id",
+		Value:		"abc",
+		Secure:		true,
+		HttpOnly:	true,
+		SameSite:	http.SameSiteLaxMode,
+	})
+
+	// bad, SameSite=None without Secure
+	c := &http.Cookie{Name: "prefs", Value: "dark", HttpOnly: true}
+	c.SameSite = http.SameSiteNoneMode
+	http.SetCookie(w, c)
+
+	// good, built up field by field
+	var auth http.Cookie
+	auth.Name = "auth"
+	auth.Expires = time.Now().Add(time.Hour)
+	auth.Secure = true
+	auth.HttpOnly = true
+	auth.SameSite = http.SameSiteStrictMode
+	http.SetCookie(w, &auth)
+
+	// bad
+	tracking := http.Cookie{Name: "tracking", Secure: false}
+	w.Header().Add("Set-Cookie", tracking.String())
+
+	// good, the helper sets the attributes
+	http.SetCookie(w, sessionCookie("abc"))
+	refresh := sessionCookie("def")
+	refresh.Name = "refresh"
+	http.SetCookie(w, refresh)
+
+	// bad
+	theme := new(http.Cookie)
+	theme.Name = "theme"
+	http.SetCookie(w, theme)
+}
+
+func sessionCookie(value string) *http.Cookie {
+	// good, attributes are set where the cookie is sent
+	return &http.Cookie{
+		Name:		"session_id",
+		Value:		value,
+		Secure:		true,
+		HttpOnly:	true,
+		SameSite:	http.SameSiteLaxMode,
+	}
+}
+
+func resendCookie(w http.ResponseWriter, c *http.Cookie) {
+	// good, the caller built the cookie
+	http.SetCookie(w, c)
+}
+
+var loginHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// bad
+	http.SetCookie(w, &http.Cookie{Name: "session", Value: r.FormValue("id")})
+})
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/handlers"
+	"github.com/rs/cors"
+)
+
+var allowedOrigins = map[string]bool{"https://app.example.com": true}
+
+func reflectOriginHandler(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	// bad
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	// bad
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+}
+
+func wildcardHandler(w http.ResponseWriter, r *http.Request) {
+	// bad
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+	// bad
+	w.Header().Add("Access-Control-Allow-Credentials", "true")
+}
+
+func checkedOriginHandler(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if allowedOrigins[origin] {
+		// good
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func publicHandler(w http.ResponseWriter, r *http.Request) {
+	// good, no credentials
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+}
+
+func corsMiddleware(h http.Handler) http.Handler {
+	c := cors.New(cors.Options{
+		// bad
+		AllowedOrigins:		[]string{"*"},
+		// bad
+		AllowCredentials:	true,
+	})
+	// bad
+	return handlers.CORS(handlers.AllowedOrigins([]string{"*"}), handlers.AllowCredentials())(c.Handler(h))
+}
+
+func originMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// bad
+		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+		h.ServeHTTP(w, r)
+	})
+}
+
+var preflightHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// bad
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	// bad
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+})
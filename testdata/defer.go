@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+func deferLoop(names []string) error {
+	for _, name := range names {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		// bad
+		defer file.Close()
+	}
+	for _, name := range names {
+		// good, the closure returns each iteration
+		func() {
+			file, err := os.Open(name)
+			if err != nil {
+				return
+			}
+			defer file.Close()
+		}()
+	}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		// good, the loop returns after it
+		defer file.Close()
+		return nil
+	}
+	return nil
+}
+
+func deferBeforeCheck(name string) error {
+	file, err := os.Open(name)
+	// bad
+	defer file.Close()
+	if err != nil {
+		return err
+	}
+
+	// good
+	file2, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file2.Close()
+	return nil
+}
+
+func deferWrite(name string, data []byte) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	// bad
+	defer out.Close()
+	_, err = out.Write(data)
+	return err
+}
+
+func deferWriteClosed(name string, data []byte) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	// good, the error of Close is returned below
+	defer out.Close()
+	if _, err = out.Write(data); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func deferWriteChecked(name string, data []byte) (err error) {
+	out, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	// good
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = out.Write(data)
+	return err
+}
+
+func deferArgs() {
+	start := time.Now()
+	// bad
+	defer log.Println("took", time.Since(start))
+	// good
+	defer func() {
+		log.Println("took", time.Since(start))
+	}()
+	// bad
+	defer log.Println(recover())
+}
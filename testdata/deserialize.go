@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"net"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+func gobHandler(w http.ResponseWriter, r *http.Request) {
+	var v interface{}
+	// bad
+	gob.NewDecoder(r.Body).Decode(&v)
+
+	var point struct{ X, Y int }
+	// good, concrete type
+	gob.NewDecoder(r.Body).Decode(&point)
+}
+
+func gobConn(conn net.Conn) {
+	dec := gob.NewDecoder(conn)
+	var msg interface{}
+	// bad
+	dec.Decode(&msg)
+}
+
+func xmlHandler(w http.ResponseWriter, r *http.Request) {
+	d := xml.NewDecoder(r.Body)
+	// bad
+	d.Strict = false
+	// bad
+	d.Entity = xml.HTMLEntity
+	var v struct{ Name string }
+	d.Decode(&v)
+}
+
+func jsonAssertions(data []byte) string {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return ""
+	}
+	// bad
+	name := m["name"].(string)
+	// good
+	if email, ok := m["email"].(string); ok {
+		name += email
+	}
+	user := m["user"]
+	// bad
+	id := user.(map[string]interface{})["id"]
+	// good
+	switch v := id.(type) {
+	case string:
+		name += v
+	}
+	return name
+}
+
+func yamlConfig(data []byte) {
+	var config interface{}
+	// bad
+	yaml.Unmarshal(data, &config)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+)
+
+func filePerms(data []byte) {
+	// bad
+	os.WriteFile("/var/lib/app/state", data, 0666)
+	// bad
+	ioutil.WriteFile("/etc/app/server.key", data, 0644)
+	// bad
+	os.MkdirAll("/var/lib/app/cache", 0777)
+	// bad
+	os.Chmod("/var/lib/app/cache", os.ModePerm)
+	// bad
+	syscall.Umask(0)
+
+	// good
+	os.WriteFile("/var/lib/app/state", data, 0644)
+	// good
+	os.WriteFile("/etc/app/server.key", data, 0600)
+	// good
+	os.Mkdir("/var/lib/app/tmp", 0755)
+	// good
+	syscall.Umask(022)
+	// good
+	os.MkdirAll("/etc/app/secrets", 0700)
+	// bad
+	os.MkdirAll("/etc/app/secrets", 0750)
+
+	// bad
+	f, err := os.OpenFile("/var/log/app.log", os.O_CREATE|os.O_WRONLY, 0662)
+	if err == nil {
+		f.Close()
+	}
+}
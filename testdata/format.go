@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+type auditLog struct {
+	prefix string
+}
+
+// logf is found as a printf wrapper
+func (a *auditLog) logf(format string, args ...interface{}) {
+	log.Printf(a.prefix+format, args...)
+}
+
+// warnf wraps a wrapper
+func warnAudit(a *auditLog, format string, args ...interface{}) {
+	a.logf("warning: "+format, args...)
+}
+
+func formatStrings(userInput string, n int, err error) {
+	a := &auditLog{prefix: "audit: "}
+
+	// bad
+	fmt.Printf(userInput)
+	// bad
+	a.logf(userInput)
+	// bad
+	log.Printf("%s %d", userInput)
+	// bad
+	fmt.Sprintf("%d items", userInput)
+	// bad
+	warnAudit(a, "%s failed", n)
+	// bad
+	_ = fmt.Errorf("wrapped: %t", n)
+
+	// good
+	fmt.Printf("%s", userInput)
+	// good
+	a.logf("%d items, %v", n, err)
+	// good
+	_ = fmt.Errorf("wrapped: %w", errors.New("x"))
+	// good
+	fmt.Printf("%*d%%\n", 5, n)
+
+	names := []string{userInput}
+	pair := struct{ Key, Value string }{"k", userInput}
+	// good, elements are printed one by one
+	fmt.Printf("%s %q %s %s", names, names, pair, &pair)
+	// good
+	fmt.Printf("%x", map[string][]byte{"k": nil})
+	// bad
+	fmt.Printf("%d", names)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func fanOutHandler(w http.ResponseWriter, r *http.Request) {
+	var urls []string
+	json.NewDecoder(r.Body).Decode(&urls)
+
+	for _, u := range urls {
+		// bad, one goroutine per element of the request
+		go fetch(u)
+	}
+
+	sem := make(chan struct{}, 8)
+	for _, u := range urls {
+		sem <- struct{}{}
+		// good
+		go func(u string) {
+			defer func() { <-sem }()
+			fetch(u)
+		}(u)
+	}
+}
+
+func fetch(u string) {}
+
+func timeoutLeak() (string, bool) {
+	result := make(chan string)
+	go func() {
+		// bad, nobody receives after the timeout
+		result <- slowLookup()
+	}()
+	select {
+	case v := <-result:
+		return v, true
+	case <-time.After(time.Second):
+		return "", false
+	}
+}
+
+func slowLookup() string { return "" }
+
+func asyncHandler(w http.ResponseWriter, r *http.Request) {
+	// bad
+	go func() {
+		time.Sleep(time.Second)
+		w.Write([]byte("done"))
+	}()
+	// bad
+	go audit(r)
+
+	ctx := r.Context()
+	// good, only the context is used
+	go func() {
+		<-ctx.Done()
+	}()
+}
+
+func audit(r *http.Request) {}
+
+func waitingHandler(w http.ResponseWriter, r *http.Request) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	// good, the handler waits
+	go func() {
+		defer wg.Done()
+		w.Write([]byte("done"))
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+func servers(h http.Handler) {
+	// bad
+	http.ListenAndServe(":8080", h)
+
+	// bad, no write or idle timeout or header limit
+	srv := &http.Server{
+		Addr:		":8443",
+		Handler:	h,
+		ReadTimeout:	5 * time.Second,
+	}
+	srv.ListenAndServeTLS("cert.pem", "key.pem")
+
+	// good
+	hardened := &http.Server{
+		Addr:			":8081",
+		Handler:		h,
+		ReadHeaderTimeout:	5 * time.Second,
+		WriteTimeout:		10 * time.Second,
+	}
+	hardened.IdleTimeout = time.Minute
+	hardened.MaxHeaderBytes = 1 << 20
+	hardened.ListenAndServe()
+}
+
+func clients() {
+	// bad
+	client := &http.Client{}
+	client.Get("https://example.com")
+
+	// good
+	timed := http.Client{Timeout: 10 * time.Second}
+	timed.Get("https://example.com")
+
+	// bad
+	http.DefaultClient.Get("https://example.com")
+	// bad, reported once with the first use
+	http.Get("https://example.com")
+}
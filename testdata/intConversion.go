@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+func retInt() int {
+    return 90;
+}
+
+func stringCon() string {
+	var a int;
+	a = 123;
+	
+	// bad
+	b := string(a);
+
+	// bad
+	b = string(123)
+
+	// bad
+	c := string(80)
+
+	// bad
+	c = string(retInt())
+
+	b = c
+
+	return b
+}
+
+func stringConvKinds(a int8, b uint64, r rune, c byte) []string {
+	return []string{
+		// bad
+		string(a),
+		// bad
+		string(b),
+		// good
+		string(r),
+		// good
+		string(c),
+		// good
+		string('a'),
+	}
+}
+
+func narrowing(s string) (int32, uint16, int32) {
+	n, _ := strconv.Atoi(s)
+	m, _ := strconv.ParseInt(s, 10, 64)
+	u, _ := strconv.ParseUint(s, 10, 16)
+	k, _ := strconv.ParseInt(s, 10, 64)
+	if k > math.MaxInt32 || k < math.MinInt32 {
+		return 0, 0, 0
+	}
+	// bad
+	_ = int32(n)
+	// bad int32(m), good uint16(u) parsed as 16 bits, good int32(k) checked
+	return int32(m), uint16(u), int32(k)
+}
+
+func signedToUnsigned(n int, data []byte) []byte {
+	// bad
+	buf := make([]byte, uint(n))
+	// bad
+	_ = data[uint32(n)]
+	// bad
+	return append(buf, data[:uint(n)]...)
+}
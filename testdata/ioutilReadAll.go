@@ -1,16 +1,72 @@
 package main
 
 import(
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"strings"
+	ioutil2 "io/ioutil"
 )
 
 func testReadAll() string {
 	r := strings.NewReader("this is a test for use of ioutil.ReadAll");
 
+	// good, not network input
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return ""
 	}
 	return string(b)
 }
+
+func readBodyHandler(w http.ResponseWriter, r *http.Request) {
+	// bad
+	body, _ := io.ReadAll(r.Body)
+	// bad
+	ioutil2.ReadAll(r.Body)
+	var v map[string]string
+	// bad
+	json.NewDecoder(r.Body).Decode(&v)
+	w.Write(body)
+}
+
+func limitedBodyHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	// good
+	body, _ := io.ReadAll(r.Body)
+	w.Write(body)
+}
+
+func lateLimitHandler(w http.ResponseWriter, r *http.Request) {
+	// bad, limited only after the read
+	body, _ := io.ReadAll(r.Body)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	w.Write(body)
+}
+
+func readResponse(resp *http.Response, conn net.Conn) {
+	// good
+	io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	// bad
+	io.ReadAll(conn)
+
+	zr, err := gzip.NewReader(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	// bad, decompression bomb
+	io.Copy(&buf, zr)
+	// good
+	io.Copy(&buf, io.LimitReader(zr, 1<<20))
+}
+
+var uploadHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// bad
+	body, _ := io.ReadAll(r.Body)
+	w.Write(body)
+})
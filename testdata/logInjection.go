@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strings"
+)
+
+type credentials struct {
+	User		string
+	Password	string
+}
+
+func loggingHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.FormValue("user")
+	// bad
+	log.Printf("login attempt for %s", user)
+	// good, %q escapes newlines
+	log.Printf("login attempt for %q", user)
+	// good
+	log.Println("login attempt for", strings.ReplaceAll(user, "\n", ""))
+	// bad, only the ends are trimmed
+	log.Println("login attempt for", strings.TrimSpace(user))
+	// bad, html escaping keeps newlines
+	log.Println("login attempt for", html.EscapeString(user))
+	// bad
+	fmt.Fprintln(os.Stderr, "path:", r.URL.Path)
+	// bad
+	slog.Info("login " + user)
+
+	// bad
+	log.Println(r.Header)
+	// bad
+	log.Printf("auth: %s", r.Header.Get("Authorization"))
+	// bad
+	dump, _ := httputil.DumpRequest(r, true)
+	log.Printf("%s", dump)
+}
+
+func logSecrets(c credentials, token string) {
+	// bad
+	log.Printf("user %s password %s", c.User, c.Password)
+	// bad
+	slog.Info("issued", "token", token)
+	// bad
+	slog.Info("issued", slog.String("apiKey", token))
+	// good
+	slog.Info("issued", "user", c.User)
+}
+
+var searchHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// bad
+	log.Printf("search for %s", r.FormValue("q"))
+})
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// testdata has no go.mod, run with -goversion 1.21 or leave it unset
+func loopCapture(items []string) {
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		// bad
+		go func() {
+			defer wg.Done()
+			fmt.Println(i, item)
+		}()
+	}
+	wg.Wait()
+
+	var callbacks []func()
+	for i := 0; i < len(items); i++ {
+		// bad
+		callbacks = append(callbacks, func() { fmt.Println(items[i]) })
+		// bad
+		defer func() { fmt.Println(i) }()
+	}
+
+	for _, item := range items {
+		item := item
+		// good, shadowed copy
+		go func() { fmt.Println(item) }()
+		// good, passed as an argument
+		go func(s string) { fmt.Println(s) }(item)
+	}
+}
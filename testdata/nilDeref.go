@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+type user struct {
+	name string
+}
+
+func nilDerefUnchecked(url string) {
+	resp, err := http.Get(url)
+	// bad, err not checked yet
+	fmt.Println(resp.Status)
+	// bad, reported by defer
+	defer resp.Body.Close()
+	if err != nil {
+		return
+	}
+}
+
+func nilDerefFailed(name string) string {
+	file, err := os.Open(name)
+	if err != nil {
+		log.Println(err)
+	}
+	// bad, reached when err is not nil
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+	return info.Name()
+}
+
+func nilDerefInBranch(name string) {
+	file, err := os.Open(name)
+	if err != nil {
+		// bad
+		fmt.Println(file.Name(), err)
+		return
+	}
+	// good
+	defer file.Close()
+}
+
+func nilDerefChecked(url string) (int, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	// good
+	defer resp.Body.Close()
+	if err == nil && resp.StatusCode == 200 {
+		// good
+		return resp.StatusCode, nil
+	}
+	return 0, nil
+}
+
+func nilDerefFatal(name string) {
+	file, err := os.Open(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// good, log.Fatal does not return
+	defer file.Close()
+}
+
+func nilDerefMap(users map[string]*user, key string, v interface{}) string {
+	// bad
+	name := users[key].name
+	u := users[key]
+	// bad
+	fmt.Println(u.name)
+	if u2 := users[key]; u2 != nil {
+		// good
+		name = u2.name
+	}
+	if u3, ok := users[key]; ok {
+		// good
+		name = u3.name
+	}
+	// bad
+	name = v.(*user).name
+	if u4, ok := v.(*user); ok {
+		// good
+		name = u4.name
+	}
+	return name
+}
+
+func nilDerefJoined(name, url string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var resp *http.Response
+	if url != "" {
+		resp, err = http.Get(url)
+	} else {
+		err = errors.New("no url")
+	}
+	if err != nil {
+		return err
+	}
+	// good, err is checked whichever branch set it
+	fmt.Println(resp.Status)
+	return nil
+}
+
+func nilDerefIgnored(name string) {
+	file, _ := os.Open(name)
+	// bad
+	fmt.Println(file.Name())
+	// bad, reported once above
+	file.Sync()
+	file.Close()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type settings map[string]interface{}
+
+func panicHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	// bad, the path may have fewer parts
+	id := parts[2]
+
+	i, _ := strconv.Atoi(r.FormValue("i"))
+	items := []string{"a", "b"}
+	// bad
+	w.Write([]byte(items[i]))
+	// good, bounds checked
+	if n, err := strconv.Atoi(r.FormValue("n")); err == nil && n >= 0 && n < len(items) {
+		w.Write([]byte(items[n]))
+	}
+
+	var s settings
+	json.NewDecoder(r.Body).Decode(&s)
+	// bad, reported by deserialize
+	name := s["name"].(string)
+	// good
+	if v, ok := s["name"].(string); ok {
+		name = v
+	}
+	// bad
+	user := r.Context().Value("user").(string)
+
+	// bad
+	go notify(id, name)
+	// good
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Println(err)
+			}
+		}()
+		notify(id, name+user)
+	}()
+	lookup(id)
+}
+
+// reached from panicHandler
+func lookup(id string) string {
+	var cache map[string]string
+	if id == "" {
+		// bad
+		panic("empty id")
+	}
+	// bad, cache is nil
+	cache[id] = id
+	return cache[id]
+}
+
+func notify(id, name string) {
+	log.Println(id, name)
+}
+
+// good, not reachable from a handler
+func unreachable(v interface{}) string {
+	if v == nil {
+		panic("nil")
+	}
+	return v.(string)
+}
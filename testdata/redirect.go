@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func redirectHandler(w http.ResponseWriter, r *http.Request) {
+	next := r.URL.Query().Get("next")
+	// bad
+	http.Redirect(w, r, next, http.StatusFound)
+
+	// bad
+	w.Header().Set("Location", r.Referer())
+	// bad
+	w.Header()["Location"] = []string{r.FormValue("to")}
+
+	// good
+	http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+}
+
+func checkedRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	next := r.FormValue("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = "/"
+	}
+	// good
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+func prefixRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	next := r.FormValue("next")
+	if !strings.HasPrefix(next, "/") {
+		next = "/"
+	}
+	// bad, //evil.com starts with /
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+func hostRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	next := r.FormValue("next")
+	u, err := url.Parse(next)
+	if err != nil || u.Host != "" {
+		next = "/"
+	}
+	// bad, /\\evil.com has no host
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+func parsedRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := url.Parse(r.Header.Get("Referer"))
+	if err != nil {
+		return
+	}
+	// bad
+	http.Redirect(w, r, u.String(), http.StatusSeeOther)
+}
+
+func redirectRoutes(mux *http.ServeMux) {
+	mux.Handle("/login", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// bad
+		http.Redirect(w, r, r.PostFormValue("return_to"), http.StatusFound)
+	}))
+}
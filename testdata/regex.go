@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// good
+var userPattern = regexp.MustCompile(`^[a-z0-9_]{3,32}$`)
+
+// bad, nested repetition
+var nestedPattern = regexp.MustCompile(`^(a+)+$`)
+
+// bad, expands to a very large program
+var repeatPattern = regexp.MustCompile(`((ab){20}){20}`)
+
+// bad, \d is not part of the POSIX syntax
+var posixDigits = regexp.MustCompilePOSIX(`[a-z]+\d+`)
+
+// good
+var posixWords = regexp.MustCompilePOSIX(`[[:alpha:]]+`)
+
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	// bad
+	re, err := regexp.Compile(r.FormValue("q"))
+	if err != nil {
+		return
+	}
+	// good
+	quoted := regexp.MustCompile(regexp.QuoteMeta(r.FormValue("q")))
+	// bad
+	ok, _ := regexp.MatchString(`^\d+$`, r.FormValue("id"))
+	_, _, _ = re, quoted, ok
+}
+
+func matchAll(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		// bad
+		if regexp.MustCompile(`error`).MatchString(line) {
+			n++
+		}
+		// good
+		if userPattern.MatchString(line) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+var allowedHosts = map[string]bool{"api.example.com": true}
+
+func fetchHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+
+	// bad
+	resp, err := http.Get(target)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+
+	// bad
+	req, _ := http.NewRequestWithContext(r.Context(), "GET", "http://"+r.FormValue("host")+"/", nil)
+	// reported above, not again
+	http.DefaultClient.Do(req)
+
+	// bad
+	conn, err := net.Dial("tcp", r.Header.Get("X-Backend"))
+	if err == nil {
+		conn.Close()
+	}
+
+	// bad
+	httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: r.FormValue("h")})
+
+	// good
+	http.Get("https://api.example.com/status")
+}
+
+func checkedFetchHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := url.Parse(r.URL.Query().Get("url"))
+	if err != nil || u.Host == "" {
+		return
+	}
+	if !allowedHosts[u.Host] {
+		return
+	}
+	// good
+	http.Get(u.String())
+}
+
+func newProxy() *httputil.ReverseProxy {
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			// bad
+			req.URL.Host = req.Header.Get("X-Forwarded-Host")
+			// good
+			req.URL.Scheme = "https"
+		},
+	}
+	proxy.Director = func(req *http.Request) {
+		backend := req.Header.Get("X-Backend")
+		// bad
+		req.Host = backend
+	}
+	return proxy
+}
+
+func decodedTargetHandler(w http.ResponseWriter, r *http.Request) {
+	var target struct {
+		URL string
+	}
+	// decoding request input through &target taints target
+	json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&target)
+	// bad
+	http.Get(target.URL)
+}
+
+var fetchRoute = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// bad
+	http.Get(r.FormValue("url"))
+})
+
+func ssrfRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/fetch", func(w http.ResponseWriter, r *http.Request) {
+		// bad, reported once
+		http.Post(r.FormValue("url"), "text/plain", nil)
+	})
+}
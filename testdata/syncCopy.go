@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+type counter struct {
+	mu	sync.Mutex
+	n	map[string]int
+}
+
+// bad, value receiver copies the mutex
+func (c counter) Get(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n[key]
+}
+
+// good
+func (c *counter) Inc(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n[key]++
+}
+
+// bad, parameter by value
+func wait(wg sync.WaitGroup) {
+	wg.Wait()
+}
+
+// bad, result by value
+func snapshot(c *counter) counter {
+	// bad
+	return *c
+}
+
+func syncCopies(counters []counter) {
+	var wg sync.WaitGroup
+	// bad
+	wg2 := wg
+	wg2.Wait()
+	// bad
+	wait(wg)
+	// bad
+	for _, c := range counters {
+		_ = c.n
+	}
+	// good
+	for i := range counters {
+		counters[i].Inc("a")
+	}
+	// good, a fresh value
+	c := counter{n: map[string]int{}}
+	c.Inc("b")
+}
+
+var errEmpty = errors.New("empty")
+
+// bad, returns with the lock held
+func (c *counter) Take(key string) (int, error) {
+	c.mu.Lock()
+	n, ok := c.n[key]
+	if !ok {
+		return 0, errEmpty
+	}
+	delete(c.n, key)
+	c.mu.Unlock()
+	return n, nil
+}
+
+// good, unlocked on both paths
+func (c *counter) Drop(key string) error {
+	c.mu.Lock()
+	if _, ok := c.n[key]; !ok {
+		c.mu.Unlock()
+		return errEmpty
+	}
+	delete(c.n, key)
+	c.mu.Unlock()
+	return nil
+}
+
+type store struct {
+	sync.RWMutex
+	items []string
+}
+
+// bad, never released
+func (s *store) Len() int {
+	s.RLock()
+	n := len(s.items)
+	return n
+}
+
+// good
+func (s *store) Add(item string) {
+	s.Lock()
+	defer func() {
+		s.Unlock()
+	}()
+	s.items = append(s.items, item)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+func predictableTemp(data []byte, name string) {
+	// bad
+	f, err := os.Create(filepath.Join(os.TempDir(), "app.lock"))
+	if err == nil {
+		f.Close()
+	}
+	// bad
+	os.WriteFile("/tmp/app-cache.json", data, 0600)
+	// bad
+	g, err := os.OpenFile(filepath.Join(os.TempDir(), name), os.O_CREATE|os.O_WRONLY, 0600)
+	if err == nil {
+		g.Close()
+	}
+	// good
+	h, err := os.OpenFile(filepath.Join(os.TempDir(), name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err == nil {
+		h.Close()
+	}
+}
+
+func leakedTemp(data []byte) error {
+	// bad
+	tmp, err := ioutil.TempFile("", "upload")
+	if err != nil {
+		return err
+	}
+	tmp.Write(data)
+	return tmp.Close()
+}
+
+func removedTemp(data []byte) error {
+	// good
+	tmp, err := os.CreateTemp("", "upload")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Write(data)
+	return tmp.Close()
+}
+
+func returnedTempDir() (string, error) {
+	// good, the caller removes it
+	dir, err := os.MkdirTemp("", "work")
+	return dir, err
+}
+
+func joinedTempDir(name string) error {
+	// bad, only a file inside it is removed
+	dir, err := os.MkdirTemp("", "work")
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, name))
+}
+
+var exportHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// bad
+	tmp, err := os.CreateTemp("", "export")
+	if err != nil {
+		return
+	}
+	tmp.Close()
+})
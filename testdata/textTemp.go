@@ -1,17 +0,0 @@
-package main
-
-import "net/http"
-import "text/template"
-
-func handler(w http.ResponseWriter, r *http.Request) {
-        param1 := r.URL.Query().Get("param1")
-
-        tmpl := template.New("hello")
-        tmpl, _ = tmpl.Parse(`{{define "T"}}{{.}}{{end}}`)
-        tmpl.ExecuteTemplate(w, "T", param1)
-}
-
-func textTemp() {
-        http.HandleFunc("/", handler)
-        http.ListenAndServe(":8080", nil)
-}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+)
+
+// loaded from configuration
+var apiKey []byte
+
+func timingCompare(r *http.Request, body, key []byte) bool {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	// bad
+	if r.Header.Get("X-Hub-Signature") == expected {
+		return true
+	}
+	// bad
+	if bytes.Equal([]byte(r.Header.Get("X-Api-Key")), apiKey) {
+		return true
+	}
+	sum := sha256.Sum256(body)
+	var want [32]byte
+	// bad
+	if reflect.DeepEqual(sum, want) {
+		return true
+	}
+	token := r.FormValue("token")
+	// good, presence check
+	if token == "" {
+		return false
+	}
+	// good, not the secret itself
+	tokenType := r.FormValue("token_type")
+	if tokenType == "bearer" {
+		return false
+	}
+	// good
+	if hmac.Equal([]byte(r.Header.Get("X-Hub-Signature")), []byte(expected)) {
+		return true
+	}
+	// good
+	return subtle.ConstantTimeCompare([]byte(token), apiKey) == 1
+}
+
+type timingCredential struct {
+	Kind   string
+	Secret string
+}
+
+func timingFields(token timingCredential, given string) bool {
+	// good, the kind of a token is not secret
+	if token.Kind == "api" {
+		return false
+	}
+	// bad
+	return token.Secret == given
+}
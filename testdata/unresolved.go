@@ -0,0 +1,17 @@
+package main
+
+import "github.com/example/missing"
+
+func unresolvedChannel() {
+	// good, the type is unknown
+	ch := make(missing.Chan)
+	go func() {
+		ch <- missing.Value
+	}()
+}
+
+func unresolvedLookup() string {
+	// good, the type is unknown
+	v := missing.Table["k"]
+	return v.Name
+}
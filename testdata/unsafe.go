@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+type header struct {
+	a, b int64
+}
+
+func unsafeConversions(p *header, b []byte, fd uintptr) {
+	// bad, rule 1
+	q := (*[2]int64)(unsafe.Pointer(p))
+	// bad, rule 2
+	addr := uintptr(unsafe.Pointer(p))
+	// bad, invalid
+	r := (*int64)(unsafe.Pointer(addr))
+	// bad, rule 3
+	s := (*int64)(unsafe.Pointer(uintptr(unsafe.Pointer(p)) + unsafe.Offsetof(p.b)))
+	// bad, rule 4
+	syscall.Syscall(syscall.SYS_READ, fd, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)))
+	// bad, rule 5
+	t := (*byte)(unsafe.Pointer(reflect.ValueOf(b).Pointer()))
+	// bad
+	str := unsafe.String(&b[0], len(b))
+	// bad
+	ptr := unsafe.Pointer(p)
+	_, _, _, _, _, _ = q, r, s, t, str, ptr
+}
+
+func sliceHeaders(s string) []byte {
+	// bad, a value header does not keep s alive
+	var sh reflect.SliceHeader
+	// bad
+	sh.Data = (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+	sh.Len = len(s)
+	sh.Cap = len(s)
+	// bad
+	return *(*[]byte)(unsafe.Pointer(&sh))
+}
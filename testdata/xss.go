@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"html"
+	"net/http"
+	"text/template"
+)
+
+func textTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	param1 := r.URL.Query().Get("param1")
+
+	tmpl := template.New("hello")
+	tmpl, _ = tmpl.Parse(`{{define "T"}}{{.}}{{end}}`)
+	// bad
+	tmpl.ExecuteTemplate(w, "T", param1)
+}
+
+func bufferedTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	tmpl := template.Must(template.New("page").Parse(`<p>{{.}}</p>`))
+	// bad
+	tmpl.Execute(&buf, r.FormValue("name"))
+	w.Write(buf.Bytes())
+}
+
+func textTemplateToString() string {
+	var buf bytes.Buffer
+	tmpl := template.Must(template.New("page").Parse(`{{.}}`))
+	// good, never reaches a response
+	tmpl.Execute(&buf, "x")
+	return buf.String()
+}
+
+func escapeBypass(r *http.Request) []interface{} {
+	name := r.FormValue("name")
+	return []interface{}{
+		// bad
+		htmltemplate.HTML(name),
+		// bad
+		htmltemplate.JS("var x = " + name),
+		// bad
+		htmltemplate.URL(name),
+		// bad
+		htmltemplate.CSS(name),
+		// bad
+		htmltemplate.HTMLAttr(name),
+		// good
+		htmltemplate.HTML("<b>constant</b>"),
+	}
+}
+
+func htmlWriteHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	name := r.URL.Query().Get("name")
+
+	// bad
+	fmt.Fprintf(w, "<h1>Hello %s</h1>", name)
+	// bad
+	w.Write([]byte("<p>" + r.FormValue("msg") + "</p>"))
+	// good
+	fmt.Fprintf(w, "<h1>Hello %s</h1>", html.EscapeString(name))
+}
+
+func plainWriteHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	// good
+	fmt.Fprintf(w, "Hello %s", r.FormValue("name"))
+}
+
+func sniffedWriteHandler(w http.ResponseWriter, r *http.Request) {
+	// bad, no Content-Type so the markup is sniffed as text/html
+	fmt.Fprintf(w, "<p>%s</p>", r.FormValue("q"))
+}
+
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// bad
+	fmt.Fprintf(w, "<p>%s</p>", r.FormValue("q"))
+})
+
+func xss() {
+	http.HandleFunc("/", textTemplateHandler)
+	http.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		// good
+		fmt.Fprintf(w, "Hello %s", r.FormValue("name"))
+	})
+	http.ListenAndServe(":8080", nil)
+}
@@ -0,0 +1,264 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	register("timingCompare",
+		"this tests for secrets like tokens, signatures and MACs compared in variable time",
+		timingCompareCheck,
+		binaryExpr,
+		callExpr)
+}
+
+// comparisons that return as soon as a byte differs
+func variableTimeCompares() map[string]bool {
+	calls := make(map[string]bool)
+	calls["bytes.Equal"]		= true
+	calls["bytes.Compare"]		= true
+	calls["strings.Compare"]	= true
+	calls["strings.EqualFold"]	= true
+	calls["reflect.DeepEqual"]	= true
+
+	return calls;
+}
+
+// calls whose results are MACs or hashes
+func macCalls() map[string]bool {
+	calls := make(map[string]bool)
+	calls["(hash.Hash).Sum"]	= true
+	calls["crypto/md5.Sum"]		= true
+	calls["crypto/sha1.Sum"]	= true
+	calls["crypto/sha256.Sum224"]	= true
+	calls["crypto/sha256.Sum256"]	= true
+	calls["crypto/sha512.Sum384"]	= true
+	calls["crypto/sha512.Sum512"]	= true
+
+	return calls;
+}
+
+// name fragments of MACs and hashes, on top of -secrets.names
+var timingNames = []string{"hmac", "signature", "digest", "hash"}
+
+// whole words that are too short to match inside other names
+var timingWords = []string{"mac", "sig"}
+
+// last words of names that describe a secret rather than hold it, like tokenType
+var timingIgnoredWords = []string{"type", "kind", "name", "len", "length", "count", "url", "path", "prefix", "format", "alg", "algorithm"}
+
+// nameWords splits camelCase and snake_case names into lower case words
+func nameWords(name string) []string {
+	var words []string
+	start := 0
+	runes := []rune(name)
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && unicode.IsLetter(runes[i]) && !(unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1])) {
+			continue
+		}
+		word := strings.ToLower(strings.Trim(string(runes[start:i]), "_-. "));
+		if word != "" {
+			words = append(words, word);
+		}
+		start = i;
+	}
+	return words;
+}
+
+// isTimingSecretName reports whether a name looks like a MAC, signature, token or key
+func isTimingSecretName(name string) bool {
+	words := nameWords(name)
+	if len(words) == 0 {
+		return false;
+	}
+	last := words[len(words)-1]
+	for _, ignored := range timingIgnoredWords {
+		if last == ignored {
+			return false;
+		}
+	}
+	if isSecretName(name) {
+		return true;
+	}
+	lower := strings.ToLower(name)
+	for _, fragment := range timingNames {
+		if strings.Contains(lower, fragment) {
+			return true;
+		}
+	}
+	for _, word := range words {
+		for _, w := range timingWords {
+			if word == w {
+				return true;
+			}
+		}
+	}
+	return false;
+}
+
+// variables defined once with := or var in each package, and the value they were given
+var singleDefs = make(map[*Package]map[types.Object]ast.Expr)
+
+// definitionOf returns the expression a variable was defined with, or nil,
+// package level variables may be defined in another file
+func definitionOf(f *File, obj types.Object) ast.Expr {
+	defs, ok := singleDefs[f.pkg];
+	if !ok {
+		defs = make(map[types.Object]ast.Expr);
+		for _, file := range f.pkg.files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch x := n.(type) {
+				case *ast.AssignStmt:
+					if x.Tok != token.DEFINE || len(x.Lhs) != len(x.Rhs) {
+						break;
+					}
+					for i, lhs := range x.Lhs {
+						if id, ok := lhs.(*ast.Ident); ok {
+							if obj := f.pkg.info.Defs[id]; obj != nil {
+								defs[obj] = x.Rhs[i];
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					if len(x.Names) != len(x.Values) {
+						break;
+					}
+					for i, name := range x.Names {
+						if obj := f.pkg.info.Defs[name]; obj != nil {
+							defs[obj] = x.Values[i];
+						}
+					}
+				}
+				return true;
+			})
+		}
+		singleDefs[f.pkg] = defs;
+	}
+	return defs[obj];
+}
+
+// secretOperand returns what makes x look like a secret, a name or a MAC call,
+// following local variables back to their definitions, or ""
+func secretOperand(f *File, x ast.Expr, depth int) string {
+	macs := macCalls();
+	reason := ""
+	ast.Inspect(x, func(n ast.Node) bool {
+		if reason != "" {
+			return false;
+		}
+		switch e := n.(type) {
+		case *ast.FuncLit:
+			return false;
+		case *ast.CallExpr:
+			if name := calleeName(f, e); macs[name] {
+				reason = name;
+				return false;
+			}
+			// r.Header.Get("X-Hub-Signature")
+			if getFuncName(e) == "Get" && len(e.Args) == 1 {
+				if key, ok := stringValue(f, e.Args[0]); ok && isTimingSecretName(key) {
+					reason = key;
+					return false;
+				}
+			}
+			// len(token) is not the token
+			if id, ok := e.Fun.(*ast.Ident); ok && id.Name == "len" {
+				return false;
+			}
+		case *ast.SelectorExpr:
+			// cfg.Token is a secret by its field name, token.Type is not one
+			if isTimingSecretName(e.Sel.Name) {
+				reason = e.Sel.Name;
+			}
+			return false;
+		case *ast.Ident:
+			if isTimingSecretName(e.Name) {
+				reason = e.Name;
+				return false;
+			}
+			if obj, ok := f.pkg.info.Uses[e].(*types.Var); ok && depth < 3 {
+				if def := definitionOf(f, obj); def != nil {
+					reason = secretOperand(f, def, depth+1);
+				}
+			}
+		}
+		return true;
+	})
+	return reason;
+}
+
+// isComparableSecret reports whether x has a type secrets are kept in
+func isComparableSecret(f *File, x ast.Expr) bool {
+	t := f.pkg.info.TypeOf(x)
+	if t == nil {
+		return false;
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return u.Info()&types.IsString != 0;
+	case *types.Slice:
+		return isByte(u.Elem());
+	case *types.Array:
+		return isByte(u.Elem());
+	}
+	return false;
+}
+
+// isByte reports whether t is byte
+func isByte(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic);
+	return ok && basic.Kind() == types.Byte;
+}
+
+// isEmptyValue reports whether x is nil or "", a presence check rather than a comparison
+func isEmptyValue(f *File, x ast.Expr) bool {
+	if isNilIdent(f, x) {
+		return true;
+	}
+	s, ok := stringValue(f, x);
+	return ok && s == "";
+}
+
+func timingCompareCheck(f *File, node ast.Node) {
+	var x, y ast.Expr
+	var what string
+	switch n := node.(type) {
+	case *ast.BinaryExpr:
+		if n.Op != token.EQL && n.Op != token.NEQ {
+			return;
+		}
+		x, y, what = n.X, n.Y, n.Op.String();
+	case *ast.CallExpr:
+		name := calleeName(f, n);
+		if !variableTimeCompares()[name] || len(n.Args) != 2 {
+			return;
+		}
+		x, y, what = n.Args[0], n.Args[1], name;
+	default:
+		return;
+	}
+	if isEmptyValue(f, x) || isEmptyValue(f, y) || !isComparableSecret(f, x) {
+		return;
+	}
+	secret := x
+	reason := secretOperand(f, x, 0);
+	if reason == "" {
+		secret = y;
+		reason = secretOperand(f, y, 0);
+		if reason == "" {
+			return;
+		}
+	}
+	use := "subtle.ConstantTimeCompare"
+	if macCalls()[reason] {
+		use = "hmac.Equal or subtle.ConstantTimeCompare";
+	}
+	f.Reportf(node.Pos(), "variable time comparison of secret %s with %s, use %s: %s", f.ASTString(secret), what, use, f.ASTString(node.(ast.Expr)));
+}
@@ -0,0 +1,383 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("unsafe",
+		"this tests for uses of unsafe.Pointer and pointers crossing the cgo boundary",
+		unsafeCheck,
+		fileNode,
+		funcDecl)
+}
+
+// unsafe.Pointer rules, numbered as in the unsafe package documentation
+const (
+	unsafeRule1 = "rule 1, *T1 to *T2, T2 must be no larger and share layout with T1"
+	unsafeRule2 = "rule 2, Pointer to uintptr, the integer is not a reference and must not be converted back"
+	unsafeRule3 = "rule 3, uintptr arithmetic converted back in one expression, the result must point into the same object"
+	unsafeRule4 = "rule 4, Pointer to uintptr in a syscall argument list"
+	unsafeRule5 = "rule 5, reflect Value.Pointer or UnsafeAddr converted immediately"
+	unsafeRule6 = "rule 6, reflect SliceHeader or StringHeader Data field"
+	unsafeToPointer = "*T to Pointer, the rule depends on how the Pointer is used"
+	unsafeInvalid = "invalid, uintptr held in a variable or field is not a reference, the object may be moved or freed"
+)
+
+// syscall functions whose uintptr arguments may come from pointers, rule 4
+func unsafeSyscalls() map[string]bool {
+	calls := make(map[string]bool)
+	calls["syscall.Syscall"]		= true
+	calls["syscall.Syscall6"]		= true
+	calls["syscall.Syscall9"]		= true
+	calls["syscall.RawSyscall"]		= true
+	calls["syscall.RawSyscall6"]		= true
+	calls["(*syscall.Proc).Call"]		= true
+	calls["(*syscall.LazyProc).Call"]	= true
+
+	return calls;
+}
+
+// isUnsafePointer reports whether t is unsafe.Pointer
+func isUnsafePointer(t types.Type) bool {
+	basic, ok := t.(*types.Basic);
+	return ok && basic.Kind() == types.UnsafePointer;
+}
+
+// isUintptr reports whether t is uintptr
+func isUintptr(t types.Type) bool {
+	basic, ok := t.(*types.Basic);
+	return ok && basic.Kind() == types.Uintptr;
+}
+
+func unsafeCheck(f *File, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.File:
+		unsafePointerCheck(f, n);
+		if importsC(n) {
+			cgoCheck(f, n);
+		}
+	case *ast.FuncDecl:
+		if n.Body != nil {
+			sliceHeaderCheck(f, n.Body);
+		}
+	}
+}
+
+// unsafePointerCheck reports every conversion to or from unsafe.Pointer,
+// classified by the unsafe.Pointer rule it follows or breaks, and calls
+// of unsafe.Slice, unsafe.String and friends
+func unsafePointerCheck(f *File, file *ast.File) {
+	syscalls := unsafeSyscalls();
+	// conversions already explained as part of an outer expression
+	handled := make(map[*ast.CallExpr]bool)
+	report := func(call *ast.CallExpr, rule string) {
+		f.Reportf(call.Pos(), "unsafe.Pointer conversion, %s: %s", rule, f.ASTString(call));
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok || handled[call] {
+			return true;
+		}
+		if cgoCall(call) != "" {
+			// cgo takes Go memory as unsafe.Pointer, cgoCheck looks at what is passed
+			for _, arg := range call.Args {
+				for x := ast.Unparen(arg); ; {
+					c, ok := x.(*ast.CallExpr);
+					if !ok || len(c.Args) != 1 || (unsafeConversion(f, c) == nil && !isCType(c.Fun)) {
+						break;
+					}
+					handled[c] = true;
+					x = ast.Unparen(c.Args[0]);
+				}
+			}
+			return true;
+		}
+		if name := calleeName(f, call); syscalls[name] {
+			for _, arg := range call.Args {
+				if inner := unsafeConversion(f, arg); inner != nil {
+					handled[inner] = true;
+					// and the unsafe.Pointer(p) inside it
+					if _, p := conversion(f, inner); p != nil {
+						if innermost := unsafeConversion(f, p); innermost != nil {
+							handled[innermost] = true;
+						}
+					}
+					report(inner, unsafeRule4);
+				}
+			}
+			return true;
+		}
+		if fn, ok := f.pkg.info.Uses[funcIdent(call)].(*types.Builtin); ok {
+			switch fn.Name() {
+			case "Slice", "String", "SliceData", "StringData", "Add":
+				f.Reportf(call.Pos(), "use of unsafe.%s: %s", fn.Name(), f.ASTString(call));
+			}
+			return true;
+		}
+		target, arg := conversion(f, call);
+		if target == nil {
+			return true;
+		}
+		from := f.pkg.info.TypeOf(arg)
+		switch {
+		case isUnsafePointer(target) && isUintptr(from):
+			report(call, uintptrRule(f, arg, handled));
+		case isUnsafePointer(target):
+			// rule 1 applies once it is converted to another pointer type
+			report(call, unsafeToPointer);
+		case isUnsafePointer(from) && isUintptr(target):
+			if inner := unsafeConversion(f, arg); inner != nil {
+				handled[inner] = true;
+			}
+			report(call, unsafeRule2);
+		case isUnsafePointer(from):
+			// (*T2)(unsafe.Pointer(p)) is explained once
+			if inner := unsafeConversion(f, arg); inner != nil {
+				handled[inner] = true;
+				innerTarget, innerArg := conversion(f, inner);
+				if isUnsafePointer(innerTarget) && isUintptr(f.pkg.info.TypeOf(innerArg)) {
+					report(call, uintptrRule(f, innerArg, handled));
+					return true;
+				}
+			}
+			report(call, unsafeRule1);
+		}
+		return true;
+	})
+}
+
+// funcIdent returns the identifier naming a called function
+func funcIdent(call *ast.CallExpr) *ast.Ident {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun;
+	case *ast.SelectorExpr:
+		return fun.Sel;
+	}
+	return nil;
+}
+
+// unsafeConversion returns x if it is a conversion to or from unsafe.Pointer
+func unsafeConversion(f *File, x ast.Expr) *ast.CallExpr {
+	call, ok := ast.Unparen(x).(*ast.CallExpr);
+	if !ok {
+		return nil;
+	}
+	target, arg := conversion(f, call);
+	if target == nil {
+		return nil;
+	}
+	if isUnsafePointer(target) || isUnsafePointer(f.pkg.info.TypeOf(arg)) {
+		return call;
+	}
+	return nil;
+}
+
+// uintptrRule classifies a uintptr converted back to unsafe.Pointer
+func uintptrRule(f *File, x ast.Expr, handled map[*ast.CallExpr]bool) string {
+	x = ast.Unparen(x);
+	if call, ok := x.(*ast.CallExpr); ok {
+		switch calleeName(f, call) {
+		case "(reflect.Value).Pointer", "(reflect.Value).UnsafeAddr":
+			return unsafeRule5;
+		}
+	}
+	if sel, ok := x.(*ast.SelectorExpr); ok && sel.Sel.Name == "Data" {
+		t := f.pkg.info.TypeOf(sel.X)
+		if isNamedType(t, "reflect", "SliceHeader") || isNamedType(t, "reflect", "StringHeader") {
+			return unsafeRule6;
+		}
+	}
+	// uintptr(unsafe.Pointer(p)) + offset
+	rule := unsafeInvalid
+	ast.Inspect(x, func(n ast.Node) bool {
+		if inner := unsafeConversion(f, exprOf(n)); inner != nil {
+			handled[inner] = true;
+			rule = unsafeRule3;
+		}
+		return true;
+	})
+	return rule;
+}
+
+// exprOf returns n as an expression or nil
+func exprOf(n ast.Node) ast.Expr {
+	if x, ok := n.(ast.Expr); ok {
+		return x;
+	}
+	return nil;
+}
+
+// sliceHeaderCheck reports reflect.SliceHeader and StringHeader values,
+// which do not keep the data they describe alive, and writes to their Data fields
+func sliceHeaderCheck(f *File, body *ast.BlockStmt) {
+	isHeader := func(t types.Type) bool {
+		return isNamedType(t, "reflect", "SliceHeader") || isNamedType(t, "reflect", "StringHeader");
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CompositeLit:
+			if isHeader(f.pkg.info.TypeOf(x)) {
+				f.Reportf(x.Pos(), "reflect header built as a value, %s: %s", unsafeInvalid, f.ASTString(x.Type));
+			}
+		case *ast.ValueSpec:
+			for _, name := range x.Names {
+				t := f.pkg.info.TypeOf(name)
+				if _, ptr := t.(*types.Pointer); isHeader(t) && !ptr {
+					f.Reportf(name.Pos(), "reflect header declared as a value, %s: %s", unsafeInvalid, name.Name);
+				}
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range x.Lhs {
+				// Len and Cap are plain integers
+				if sel, ok := lhs.(*ast.SelectorExpr); ok && sel.Sel.Name == "Data" && isHeader(f.pkg.info.TypeOf(sel.X)) {
+					f.Reportf(x.Pos(), "write to reflect header field, %s: %s", unsafeRule6, f.ASTString(lhs));
+				}
+			}
+		}
+		return true;
+	})
+}
+
+// importsC reports whether a file uses cgo
+func importsC(file *ast.File) bool {
+	for _, imp := range file.Imports {
+		if imp.Path.Value == `"C"` {
+			return true;
+		}
+	}
+	return false;
+}
+
+// cgoCall returns the name of a C function called as C.name or ""
+func cgoCall(call *ast.CallExpr) string {
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		if id, ok := sel.X.(*ast.Ident); ok && id.Name == "C" {
+			return sel.Sel.Name;
+		}
+	}
+	return "";
+}
+
+// cgoCheck reports C.CString results never passed to C.free in the same function
+// and Go pointers passed to C functions. The type checker does not resolve C
+// so this works on the syntax alone.
+func cgoCheck(f *File, file *ast.File) {
+	for _, decl := range file.Decls {
+		fun, ok := decl.(*ast.FuncDecl);
+		if !ok || fun.Body == nil {
+			continue
+		}
+		// C strings by variable name, and those freed
+		allocated := make(map[string]*ast.CallExpr)
+		var order []string
+		freed := make(map[string]bool)
+		ast.Inspect(fun.Body, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.AssignStmt:
+				if len(x.Lhs) != len(x.Rhs) {
+					break;
+				}
+				for i, rhs := range x.Rhs {
+					if call, ok := rhs.(*ast.CallExpr); ok && (cgoCall(call) == "CString" || cgoCall(call) == "CBytes") {
+						if id, ok := x.Lhs[i].(*ast.Ident); ok {
+							allocated[id.Name] = call;
+							order = append(order, id.Name);
+						}
+					}
+				}
+			case *ast.CallExpr:
+				switch name := cgoCall(x); name {
+				case "":
+				case "free":
+					for _, arg := range x.Args {
+						if id := rootIdent(stripConversions(f, arg)); id != nil {
+							freed[id.Name] = true;
+						}
+					}
+				default:
+					for _, arg := range x.Args {
+						cgoArgCheck(f, x, arg);
+					}
+				}
+			}
+			return true;
+		})
+		for _, name := range order {
+			if !freed[name] {
+				call := allocated[name]
+				f.Reportf(call.Pos(), "C.%s result %s is never freed with C.free: %s", cgoCall(call), name, f.ASTString(call));
+			}
+		}
+	}
+}
+
+// stripConversions returns the innermost argument of nested conversions like unsafe.Pointer(cs)
+func stripConversions(f *File, x ast.Expr) ast.Expr {
+	for {
+		call, ok := ast.Unparen(x).(*ast.CallExpr);
+		if !ok || len(call.Args) != 1 {
+			return x;
+		}
+		if !f.pkg.info.Types[call.Fun].IsType() && !isCType(call.Fun) {
+			return x;
+		}
+		x = call.Args[0];
+	}
+}
+
+// C scalar types cgo defines, a call C.name(x) with any other name is taken as a function call
+var cScalarTypes = map[string]bool{
+	"char": true, "schar": true, "uchar": true, "short": true, "ushort": true,
+	"int": true, "uint": true, "long": true, "ulong": true, "longlong": true,
+	"ulonglong": true, "float": true, "double": true, "size_t": true,
+}
+
+// isCType reports whether x names a C type like C.int or *C.char
+func isCType(x ast.Expr) bool {
+	x = ast.Unparen(x);
+	pointer := false
+	if star, ok := x.(*ast.StarExpr); ok {
+		x, pointer = star.X, true;
+	}
+	if sel, ok := x.(*ast.SelectorExpr); ok {
+		id, ok := sel.X.(*ast.Ident);
+		if !ok || id.Name != "C" {
+			return false;
+		}
+		return pointer || cScalarTypes[sel.Sel.Name] || strings.HasPrefix(sel.Sel.Name, "struct_");
+	}
+	return false;
+}
+
+// cgoArgCheck reports Go memory passed to a C function
+func cgoArgCheck(f *File, call *ast.CallExpr, arg ast.Expr) {
+	inner := stripConversions(f, arg)
+	if c, ok := ast.Unparen(inner).(*ast.CallExpr); ok {
+		switch cgoCall(c) {
+		case "CString", "CBytes":
+			f.Reportf(c.Pos(), "C.%s passed directly to C.%s is never freed", cgoCall(c), cgoCall(call));
+		}
+		return;
+	}
+	if u, ok := ast.Unparen(inner).(*ast.UnaryExpr); ok && u.Op == token.AND {
+		f.Reportf(arg.Pos(), "Go pointer passed to C.%s, it must not contain Go pointers or be kept by C: %s", cgoCall(call), f.ASTString(arg));
+		return;
+	}
+	if t := f.pkg.info.TypeOf(inner); t != nil {
+		switch t.Underlying().(type) {
+		case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature:
+			if !strings.HasPrefix(f.ASTString(inner), "C.") {
+				f.Reportf(arg.Pos(), "Go pointer passed to C.%s, it must not contain Go pointers or be kept by C: %s", cgoCall(call), f.ASTString(arg));
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2018 Terence Tarvis.  All rights reserved.
+//
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+)
+
+func init() {
+	register("xss",
+		"this tests for unescaped data written to HTTP responses",
+		xssCheck,
+		funcDecl,
+		funcLit,
+		callExpr)
+}
+
+// html/template types that mark content as safe and skip escaping
+func escapeBypassTypes() map[string]bool {
+	types := make(map[string]bool)
+	types["HTML"]		= true
+	types["HTMLAttr"]	= true
+	types["JS"]		= true
+	types["JSStr"]		= true
+	types["URL"]		= true
+	types["CSS"]		= true
+	types["Srcset"]		= true
+
+	return types;
+}
+
+// functions that write their remaining arguments to the writer in the first argument
+func responseWrites() map[string]bool {
+	calls := make(map[string]bool)
+	calls["fmt.Fprintf"]			= true
+	calls["fmt.Fprint"]			= true
+	calls["fmt.Fprintln"]			= true
+	calls["io.WriteString"]			= true
+	calls["io.Copy"]			= true
+
+	return calls;
+}
+
+// responseWrite splits a call writing to an http.ResponseWriter
+// into the data written, it returns nil for any other call
+func responseWrite(f *File, call *ast.CallExpr, writes map[string]bool) []ast.Expr {
+	name := calleeName(f, call);
+	if name == "(net/http.ResponseWriter).Write" {
+		// w.Write(b)
+		return call.Args;
+	}
+	if writes[name] && len(call.Args) > 1 && isResponseWriter(f, call.Args[0]) {
+		return call.Args[1:];
+	}
+	if strings.HasSuffix(name, ".WriteTo") && len(call.Args) == 1 && isResponseWriter(f, call.Args[0]) {
+		// buf.WriteTo(w)
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+			return []ast.Expr{sel.X};
+		}
+	}
+	return nil;
+}
+
+func xssCheck(f *File, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.CallExpr:
+		escapeBypassCheck(f, n);
+	case *ast.FuncDecl:
+		if n.Body != nil {
+			responseCheck(f, n.Body);
+		}
+	case *ast.FuncLit:
+		// http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {...})
+		if body := handlerBody(f, n); body != nil {
+			responseCheck(f, body);
+		}
+	}
+}
+
+// inspectOwn is ast.Inspect without the handler literals nested in node,
+// those are checked on their own
+func inspectOwn(f *File, node ast.Node, fn func(ast.Node) bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.FuncLit); ok && isHandler(f.pkg.info.TypeOf(lit)) {
+			return false;
+		}
+		return fn(n);
+	})
+}
+
+// escapeBypassCheck reports conversions like template.HTML(s) of non-constant data
+func escapeBypassCheck(f *File, call *ast.CallExpr) {
+	if len(call.Args) != 1 || !f.pkg.info.Types[call.Fun].IsType() {
+		return;
+	}
+	var sel *ast.SelectorExpr
+	switch fun := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		sel = fun;
+	case *ast.ParenExpr:
+		sel, _ = fun.X.(*ast.SelectorExpr);
+	}
+	if sel == nil || !escapeBypassTypes()[sel.Sel.Name] {
+		return;
+	}
+	if !isNamedType(f.pkg.info.TypeOf(call), "html/template", sel.Sel.Name) {
+		return;
+	}
+	if isConstant(f, call.Args[0]) {
+		return;
+	}
+	f.Reportf(call.Pos(), "audit conversion of non-constant data to %s, it will not be escaped: %s", sel.Sel.Name, f.ASTString(call));
+}
+
+// isResponseWriter reports whether x is an http.ResponseWriter
+func isResponseWriter(f *File, x ast.Expr) bool {
+	return isNamedType(f.pkg.info.TypeOf(x), "net/http", "ResponseWriter");
+}
+
+// responseContentType looks for w.Header().Set("Content-Type", ...)
+// and returns the type set and whether one was set at all
+func responseContentType(f *File, body ast.Node) (string, bool) {
+	ctype, set := "", false
+	inspectOwn(f, body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok || len(call.Args) != 2 {
+			return !set;
+		}
+		switch calleeName(f, call) {
+		case "(net/http.Header).Set", "(net/http.Header).Add":
+		default:
+			return !set;
+		}
+		key := f.pkg.info.Types[call.Args[0]].Value
+		if key == nil || key.Kind() != constant.String || !strings.EqualFold(constant.StringVal(key), "Content-Type") {
+			return !set;
+		}
+		set = true;
+		// a type that is not constant is unknown, but not sniffed
+		if value := f.pkg.info.Types[call.Args[1]].Value; value != nil && value.Kind() == constant.String {
+			ctype = strings.ToLower(constant.StringVal(value));
+		}
+		return !set;
+	})
+	return ctype, set;
+}
+
+// responseCheck reports text/template output and request data
+// written to an http.ResponseWriter within a function body
+func responseCheck(f *File, body *ast.BlockStmt) {
+	writes := responseWrites();
+	// without a Content-Type net/http sniffs the body and serves markup as text/html
+	ctype, set := responseContentType(f, body);
+	html := !set || strings.Contains(ctype, "html")
+	t := newTaint(f, body, isRequestInput, "escape");
+
+	// variables, usually buffers, whose contents end up in a response
+	toResponse := make(map[types.Object]bool)
+	inspectOwn(f, body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		for _, arg := range responseWrite(f, call, writes) {
+			// w.Write(buf.Bytes()) writes buf
+			if c, ok := arg.(*ast.CallExpr); ok {
+				if sel, ok := c.Fun.(*ast.SelectorExpr); ok {
+					arg = sel.X;
+				}
+			}
+			if id := rootIdent(arg); id != nil {
+				toResponse[t.objectOf(id)] = true;
+			}
+		}
+		return true;
+	})
+
+	// buffers text/template executes into, reported with the template
+	templated := make(map[types.Object]bool)
+	inspectOwn(f, body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && len(call.Args) > 0 {
+			switch calleeName(f, call) {
+			case "(*text/template.Template).Execute", "(*text/template.Template).ExecuteTemplate":
+				if id := rootIdent(call.Args[0]); id != nil {
+					templated[t.objectOf(id)] = true;
+				}
+			}
+		}
+		return true;
+	})
+
+	inspectOwn(f, body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr);
+		if !ok {
+			return true;
+		}
+		switch calleeName(f, call) {
+		case "(*text/template.Template).Execute", "(*text/template.Template).ExecuteTemplate":
+			if len(call.Args) == 0 {
+				break;
+			}
+			w := call.Args[0]
+			if isResponseWriter(f, w) {
+				f.Reportf(call.Pos(), "text/template output written to HTTP response without escaping: %s", f.ASTString(call));
+			} else if id := rootIdent(w); id != nil && toResponse[t.objectOf(id)] {
+				f.Reportf(call.Pos(), "text/template output copied to HTTP response without escaping: %s", f.ASTString(call));
+			}
+		default:
+			if !html {
+				break;
+			}
+			for _, arg := range responseWrite(f, call, writes) {
+				// w.Write(buf.Bytes())
+				written := arg
+				if c, ok := arg.(*ast.CallExpr); ok {
+					if sel, ok := c.Fun.(*ast.SelectorExpr); ok {
+						written = sel.X;
+					}
+				}
+				if id := rootIdent(written); id != nil && templated[t.objectOf(id)] {
+					continue
+				}
+				if t.isUnchecked(arg) {
+					f.Reportf(call.Pos(), "request data written to HTML response without escaping: %s", f.ASTString(call));
+					break;
+				}
+			}
+		}
+		return true;
+	})
+}